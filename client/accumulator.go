@@ -0,0 +1,272 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// ResponsePart is the content assembled for one content part of an output
+// item, or for the arguments of a function call item.
+type ResponsePart struct {
+	ItemID       string
+	OutputIndex  int
+	ContentIndex int
+	Text         string
+	Transcript   string
+	// Audio is the decoded PCM of all response.audio.delta of the part.
+	Audio     []byte
+	Arguments string
+	Done      bool
+}
+
+// AccumulatedResponse is a response assembled from the server events.
+// Response.Output holds the output items, their content filled with the
+// assembled text and transcripts once the response is done.
+type AccumulatedResponse struct {
+	Response events.Response
+	// Parts are ordered by output index then content index.
+	Parts []*ResponsePart
+	Done  bool
+}
+
+// Text returns the text of all parts.
+func (a *AccumulatedResponse) Text() string {
+	var sb strings.Builder
+	for _, part := range a.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String()
+}
+
+// Transcript returns the audio transcript of all parts.
+func (a *AccumulatedResponse) Transcript() string {
+	var sb strings.Builder
+	for _, part := range a.Parts {
+		sb.WriteString(part.Transcript)
+	}
+	return sb.String()
+}
+
+// Audio returns the PCM audio of all parts.
+func (a *AccumulatedResponse) Audio() []byte {
+	var audio []byte
+	for _, part := range a.Parts {
+		audio = append(audio, part.Audio...)
+	}
+	return audio
+}
+
+func (a *AccumulatedResponse) part(event *events.Event) *ResponsePart {
+	for _, part := range a.Parts {
+		if part.OutputIndex == event.OutputIndex && part.ContentIndex == event.ContentIndex {
+			if part.ItemID == "" {
+				part.ItemID = event.ItemID
+			}
+			return part
+		}
+	}
+	part := &ResponsePart{ItemID: event.ItemID, OutputIndex: event.OutputIndex, ContentIndex: event.ContentIndex}
+	a.Parts = append(a.Parts, part)
+	sort.SliceStable(a.Parts, func(i, j int) bool {
+		if a.Parts[i].OutputIndex != a.Parts[j].OutputIndex {
+			return a.Parts[i].OutputIndex < a.Parts[j].OutputIndex
+		}
+		return a.Parts[i].ContentIndex < a.Parts[j].ContentIndex
+	})
+	return part
+}
+
+func (a *AccumulatedResponse) setItem(index int, item *events.Item) {
+	if item == nil {
+		return
+	}
+	for len(a.Response.Output) <= index {
+		a.Response.Output = append(a.Response.Output, events.Item{})
+	}
+	a.Response.Output[index] = *item
+}
+
+// merge takes the fields of r, keeping the assembled output items if r has none.
+func (a *AccumulatedResponse) merge(r *events.Response) {
+	if r == nil {
+		return
+	}
+	output := a.Response.Output
+	a.Response = *r
+	if len(a.Response.Output) == 0 {
+		a.Response.Output = output
+	}
+}
+
+// fillOutput copies the assembled parts into the content of the output items.
+func (a *AccumulatedResponse) fillOutput() {
+	for _, part := range a.Parts {
+		if part.OutputIndex >= len(a.Response.Output) {
+			continue
+		}
+		item := &a.Response.Output[part.OutputIndex]
+		if item.Type == events.ItemTypeFunctionCall {
+			if item.Arguments == "" {
+				item.Arguments = part.Arguments
+			}
+			continue
+		}
+		for len(item.Content) <= part.ContentIndex {
+			item.Content = append(item.Content, events.Content{})
+		}
+		content := &item.Content[part.ContentIndex]
+		if content.Text == nil && part.Text != "" {
+			text := part.Text
+			content.Type, content.Text = events.ContentTypeText, &text
+		}
+		if content.Transcript == nil && part.Transcript != "" {
+			transcript := part.Transcript
+			content.Type, content.Transcript = events.ContentTypeAudio, &transcript
+		}
+	}
+}
+
+func (a *AccumulatedResponse) clone() *AccumulatedResponse {
+	c := *a
+	c.Response.Output = append([]events.Item(nil), a.Response.Output...)
+	c.Parts = make([]*ResponsePart, len(a.Parts))
+	for i, part := range a.Parts {
+		p := *part
+		p.Audio = append([]byte(nil), part.Audio...)
+		c.Parts[i] = &p
+	}
+	return &c
+}
+
+// Accumulator folds the response.* server events into AccumulatedResponse
+// values keyed by response id. Register Handle as a handler, for example with
+// Attach. The callbacks run in the handler and receive the live value, which
+// must not be retained after they return.
+type Accumulator struct {
+	// OnProgress is called after every event that changed a response.
+	OnProgress func(resp *AccumulatedResponse, event *events.Event)
+	// OnDone is called on response.done with the complete response.
+	OnDone func(resp *AccumulatedResponse)
+
+	lock      sync.Mutex
+	responses map[string]*AccumulatedResponse
+}
+
+func NewAccumulator() *Accumulator {
+	return &Accumulator{responses: make(map[string]*AccumulatedResponse)}
+}
+
+// Attach registers the accumulator on d and returns a function detaching it.
+func (a *Accumulator) Attach(d *Dispatcher) (detach func()) {
+	return d.OnAny(a.Handle)
+}
+
+// Response returns a copy of the response in progress with the given id.
+func (a *Accumulator) Response(id string) (*AccumulatedResponse, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	resp, ok := a.responses[id]
+	if !ok {
+		return nil, false
+	}
+	return resp.clone(), true
+}
+
+// Handle applies a server event, events not about responses are ignored.
+func (a *Accumulator) Handle(event *events.Event) error {
+	a.lock.Lock()
+	resp, err := a.apply(event)
+	a.lock.Unlock()
+	if err != nil || resp == nil {
+		return err
+	}
+	if a.OnProgress != nil {
+		a.OnProgress(resp, event)
+	}
+	if resp.Done && a.OnDone != nil {
+		a.OnDone(resp)
+	}
+	return nil
+}
+
+// apply must be called with a.lock held.
+func (a *Accumulator) apply(event *events.Event) (*AccumulatedResponse, error) {
+	id := event.ResponseID
+	if event.Response != nil && event.Response.ID != "" {
+		id = event.Response.ID
+	}
+	switch event.Type {
+	case events.RealtimeServerEventResponseCreated,
+		events.RealtimeServerEventResponseDone,
+		events.RealtimeServerEventResponseOutputItemAdded,
+		events.RealtimeServerEventResponseOutputItemDone,
+		events.RealtimeServerEventResponseContentPartAdded,
+		events.RealtimeServerEventResponseContentPartDone,
+		events.RealtimeServerEventResponseTextDelta,
+		events.RealtimeServerEventResponseTextDone,
+		events.RealtimeServerEventResponseAudioTranscriptDelta,
+		events.RealtimeServerEventResponseAudioTranscriptDone,
+		events.RealtimeServerEventResponseAudioDelta,
+		events.RealtimeServerEventResponseAudioDone,
+		events.RealtimeServerEventResponseFunctionCallArgumentsDelta,
+		events.RealtimeServerEventResponseFunctionCallArgumentsDone:
+	default:
+		return nil, nil
+	}
+
+	resp, ok := a.responses[id]
+	if !ok {
+		resp = &AccumulatedResponse{Response: events.Response{ID: id}}
+		a.responses[id] = resp
+	}
+	switch event.Type {
+	case events.RealtimeServerEventResponseCreated:
+		resp.merge(event.Response)
+	case events.RealtimeServerEventResponseOutputItemAdded, events.RealtimeServerEventResponseOutputItemDone:
+		resp.setItem(event.OutputIndex, event.Item)
+	case events.RealtimeServerEventResponseContentPartAdded:
+		resp.part(event)
+	case events.RealtimeServerEventResponseContentPartDone:
+		resp.part(event).Done = true
+	case events.RealtimeServerEventResponseTextDelta:
+		resp.part(event).Text += event.Delta
+	case events.RealtimeServerEventResponseTextDone:
+		part := resp.part(event)
+		if event.Text != nil {
+			part.Text = *event.Text
+		}
+		part.Done = true
+	case events.RealtimeServerEventResponseAudioTranscriptDelta:
+		resp.part(event).Transcript += event.Delta
+	case events.RealtimeServerEventResponseAudioTranscriptDone:
+		part := resp.part(event)
+		if event.Transcript != nil {
+			part.Transcript = *event.Transcript
+		}
+	case events.RealtimeServerEventResponseAudioDelta:
+		pcm, err := base64.StdEncoding.DecodeString(event.Delta)
+		if err != nil {
+			return nil, fmt.Errorf("decode audio delta of response %s failed: %v", id, err)
+		}
+		part := resp.part(event)
+		part.Audio = append(part.Audio, pcm...)
+	case events.RealtimeServerEventResponseAudioDone:
+		resp.part(event).Done = true
+	case events.RealtimeServerEventResponseFunctionCallArgumentsDelta:
+		resp.part(event).Arguments += event.Delta
+	case events.RealtimeServerEventResponseFunctionCallArgumentsDone:
+		part := resp.part(event)
+		part.Arguments, part.Done = event.Arguments, true
+	case events.RealtimeServerEventResponseDone:
+		resp.merge(event.Response)
+		resp.fillOutput()
+		resp.Done = true
+		delete(a.responses, id)
+	}
+	return resp, nil
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestAccumulator(t *testing.T) {
+	acc := NewAccumulator()
+	var done *AccumulatedResponse
+	progress := 0
+	acc.OnProgress = func(resp *AccumulatedResponse, event *events.Event) { progress++ }
+	acc.OnDone = func(resp *AccumulatedResponse) { done = resp }
+
+	pcm1, pcm2 := []byte{1, 0, 2, 0}, []byte{3, 0}
+	item := &events.Item{ID: "item_1", Type: events.ItemTypeMessage, Role: events.ItemRoleAssistant}
+	call := &events.Item{ID: "item_2", Type: events.ItemTypeFunctionCall, Name: "SearchWeather", CallId: "call_1"}
+	stream := []*events.Event{
+		{Type: events.RealtimeServerEventResponseCreated, Response: &events.Response{ID: "resp_1", Status: events.ResponseStatusInProgress}},
+		{Type: events.RealtimeServerEventResponseOutputItemAdded, ResponseID: "resp_1", OutputIndex: 0, Item: item},
+		{Type: events.RealtimeServerEventResponseAudioTranscriptDelta, ResponseID: "resp_1", ItemID: "item_1", Delta: "你好"},
+		{Type: events.RealtimeServerEventResponseAudioDelta, ResponseID: "resp_1", ItemID: "item_1", Delta: base64.StdEncoding.EncodeToString(pcm1)},
+		{Type: events.RealtimeServerEventResponseAudioTranscriptDelta, ResponseID: "resp_1", ItemID: "item_1", Delta: "呀"},
+		{Type: events.RealtimeServerEventResponseAudioDelta, ResponseID: "resp_1", ItemID: "item_1", Delta: base64.StdEncoding.EncodeToString(pcm2)},
+		{Type: events.RealtimeServerEventResponseOutputItemAdded, ResponseID: "resp_1", OutputIndex: 1, Item: call},
+		{Type: events.RealtimeServerEventResponseFunctionCallArgumentsDelta, ResponseID: "resp_1", ItemID: "item_2", OutputIndex: 1, Delta: `{"localtion":`},
+		{Type: events.RealtimeServerEventResponseFunctionCallArgumentsDelta, ResponseID: "resp_1", ItemID: "item_2", OutputIndex: 1, Delta: `"北京"}`},
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{
+			ID: "resp_1", Status: events.ResponseStatusCompleted, Usage: &events.Usage{TotalTokens: 42},
+		}},
+	}
+	for _, event := range stream {
+		if err := acc.Handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if done == nil {
+		t.Fatal("OnDone not called")
+	}
+	if progress != len(stream) {
+		t.Errorf("OnProgress called %d times, want %d", progress, len(stream))
+	}
+	if got := done.Transcript(); got != "你好呀" {
+		t.Errorf("transcript = %q", got)
+	}
+	if got := done.Audio(); !bytes.Equal(got, append(pcm1, pcm2...)) {
+		t.Errorf("audio = %v", got)
+	}
+	if done.Response.Usage == nil || done.Response.Usage.TotalTokens != 42 {
+		t.Errorf("usage = %+v", done.Response.Usage)
+	}
+	if len(done.Response.Output) != 2 {
+		t.Fatalf("output has %d items, want 2", len(done.Response.Output))
+	}
+	if c := done.Response.Output[0].Content; len(c) != 1 || c[0].Transcript == nil || *c[0].Transcript != "你好呀" {
+		t.Errorf("message content = %+v", c)
+	}
+	if got := done.Response.Output[1].Arguments; got != `{"localtion":"北京"}` {
+		t.Errorf("arguments = %q", got)
+	}
+	if _, ok := acc.Response("resp_1"); ok {
+		t.Error("done response still tracked")
+	}
+}
@@ -0,0 +1,336 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-audio/wav"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
+)
+
+// AudioFormat describes little-endian PCM audio. 8 bit samples are unsigned,
+// wider samples signed, as in WAV files.
+type AudioFormat struct {
+	SampleRate int
+	Channels   int
+	BitDepth   int
+}
+
+var (
+	// InputPCMFormat is the audio expected for the "pcm" and "wav" input
+	// audio formats of a session.
+	InputPCMFormat = AudioFormat{SampleRate: 16000, Channels: 1, BitDepth: 16}
+	// OutputPCMFormat is the audio of response.audio.delta for the "pcm"
+	// output audio format of a session.
+	OutputPCMFormat = AudioFormat{SampleRate: 24000, Channels: 1, BitDepth: 16}
+)
+
+func (f AudioFormat) validate() error {
+	if f.SampleRate <= 0 || f.Channels <= 0 {
+		return fmt.Errorf("invalid audio format %+v", f)
+	}
+	switch f.BitDepth {
+	case 8, 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("unsupported bit depth %d", f.BitDepth)
+}
+
+// frameSize is the size in bytes of one sample of every channel.
+func (f AudioFormat) frameSize() int {
+	return f.Channels * f.BitDepth / 8
+}
+
+// Duration returns the duration of n bytes of audio.
+func (f AudioFormat) Duration(n int) time.Duration {
+	return time.Duration(n/f.frameSize()) * time.Second / time.Duration(f.SampleRate)
+}
+
+// Bytes returns the size of d of audio, rounded down to whole frames.
+func (f AudioFormat) Bytes(d time.Duration) int {
+	return int(d*time.Duration(f.SampleRate)/time.Second) * f.frameSize()
+}
+
+const defaultAudioFrameDuration = 100 * time.Millisecond
+
+// AudioStreamer sends audio to a session as input_audio_buffer.append events,
+// converting it to InputPCMFormat and cutting it into frames of FrameDuration
+// sent at real-time pace.
+type AudioStreamer struct {
+	// InputAudioFormat is Session.InputAudioFormat, "pcm" or "wav". With
+	// "wav" every append carries a WAV file holding one frame.
+	InputAudioFormat string
+	// FrameDuration is the audio per append, 100ms by default.
+	FrameDuration time.Duration
+	// Unpaced sends the frames as fast as possible.
+	Unpaced bool
+	// Commit sends input_audio_buffer.commit after the audio, CreateResponse
+	// then sends response.create.
+	Commit         bool
+	CreateResponse bool
+	// VAD, if set, processes every frame after it is sent and is flushed
+	// after the last one.
+	VAD *VAD
+
+	client RealtimeClient
+}
+
+func NewAudioStreamer(c RealtimeClient) *AudioStreamer {
+	return &AudioStreamer{InputAudioFormat: "pcm", FrameDuration: defaultAudioFrameDuration, client: c}
+}
+
+// StreamFile streams a WAV file, or a raw PCM file of format src if the file
+// has no .wav extension.
+func (s *AudioStreamer) StreamFile(ctx context.Context, path string, src AudioFormat) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file failed: %v", err)
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		return s.StreamWAV(ctx, file)
+	}
+	return s.StreamPCM(ctx, file, src)
+}
+
+// StreamWAV streams the PCM data of a WAV file.
+func (s *AudioStreamer) StreamWAV(ctx context.Context, r io.ReadSeeker) error {
+	decoder := wav.NewDecoder(r)
+	if !decoder.IsValidFile() {
+		return fmt.Errorf("invalid WAV file")
+	}
+	// 1 is the PCM format tag, compressed WAV files are not supported.
+	if decoder.WavAudioFormat != 1 {
+		return fmt.Errorf("unsupported WAV audio format %d", decoder.WavAudioFormat)
+	}
+	if err := decoder.FwdToPCM(); err != nil {
+		return fmt.Errorf("read WAV file failed: %v", err)
+	}
+	src := AudioFormat{SampleRate: int(decoder.SampleRate), Channels: int(decoder.NumChans), BitDepth: int(decoder.BitDepth)}
+	return s.StreamPCM(ctx, io.LimitReader(decoder.PCMChunk.R, int64(decoder.PCMChunk.Size)), src)
+}
+
+// StreamPCM streams raw PCM audio of format src read from r until EOF.
+func (s *AudioStreamer) StreamPCM(ctx context.Context, r io.Reader, src AudioFormat) error {
+	if err := src.validate(); err != nil {
+		return err
+	}
+	wrapWAV := false
+	switch s.InputAudioFormat {
+	case "", "pcm":
+	case "wav":
+		wrapWAV = true
+	default:
+		return fmt.Errorf("unsupported input audio format %q", s.InputAudioFormat)
+	}
+	frameDuration := s.FrameDuration
+	if frameDuration <= 0 {
+		frameDuration = defaultAudioFrameDuration
+	}
+	frameBytes := InputPCMFormat.Bytes(frameDuration)
+	if frameBytes == 0 {
+		return fmt.Errorf("frame duration %v is too short", frameDuration)
+	}
+
+	conv := newPCMConverter(src, InputPCMFormat)
+	in := make([]byte, src.Bytes(frameDuration)+src.frameSize())
+	var pending []byte
+	start := time.Now()
+	var sent time.Duration
+	send := func(frame []byte) error {
+		if !s.Unpaced {
+			if err := sleepUntil(ctx, start.Add(sent)); err != nil {
+				return err
+			}
+		}
+		audio := frame
+		if wrapWAV {
+			audio, _ = tools.Pcm2Wav(frame, InputPCMFormat.SampleRate, InputPCMFormat.Channels, InputPCMFormat.BitDepth)
+		}
+		event := &events.Event{
+			Type:  events.RealtimeClientEventInputAudioBufferAppend,
+			Audio: base64.StdEncoding.EncodeToString(audio),
+		}
+		if err := s.client.SendContext(ctx, event); err != nil {
+			return err
+		}
+		sent += frameDuration
+		if s.VAD != nil {
+			return s.VAD.Process(ctx, frame)
+		}
+		return nil
+	}
+
+	var partial []byte // bytes of an incomplete source frame
+	for {
+		n, err := io.ReadFull(r, in[len(partial):])
+		copy(in, partial)
+		data := in[:len(partial)+n]
+		whole := len(data) - len(data)%src.frameSize()
+		pending = append(pending, conv.convert(data[:whole])...)
+		partial = append(partial[:0], data[whole:]...)
+		for len(pending) >= frameBytes {
+			if sendErr := send(pending[:frameBytes]); sendErr != nil {
+				return sendErr
+			}
+			pending = pending[frameBytes:]
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read audio failed: %v", err)
+		}
+	}
+	if len(pending) > 0 {
+		if err := send(pending); err != nil {
+			return err
+		}
+	}
+	if s.VAD != nil {
+		if err := s.VAD.Flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.Commit {
+		if err := s.client.SendContext(ctx, &events.Event{Type: events.RealtimeClientEventInputAudioBufferCommit}); err != nil {
+			return err
+		}
+		if s.CreateResponse {
+			return s.client.SendContext(ctx, &events.Event{Type: events.RealtimeClientEventResponseCreate})
+		}
+	}
+	return nil
+}
+
+// pcmConverter converts PCM to 16 bit mono audio, averaging the channels and
+// resampling linearly. When downsampling, a low-pass filter first removes the
+// frequencies the output rate cannot carry, which would otherwise alias into
+// the speech band. It keeps state between calls to convert a stream.
+type pcmConverter struct {
+	src, dst AudioFormat
+	step     float64 // source samples per output sample
+	pos      float64 // position of the next output sample, 1 is the first new sample
+	prev     float64 // last sample of the previous call
+	lowPass  *lowPass
+}
+
+func newPCMConverter(src, dst AudioFormat) *pcmConverter {
+	c := &pcmConverter{src: src, dst: dst, step: float64(src.SampleRate) / float64(dst.SampleRate), pos: 1}
+	if src.SampleRate > dst.SampleRate {
+		// Cut off at 90% of the output Nyquist frequency, leaving room for
+		// the transition band of the filter.
+		c.lowPass = newLowPass(0.45*float64(dst.SampleRate)/float64(src.SampleRate), lowPassTaps)
+	}
+	return c
+}
+
+// convert converts whole frames of source audio.
+func (c *pcmConverter) convert(data []byte) []byte {
+	if c.src == c.dst {
+		return append([]byte(nil), data...)
+	}
+	width := c.src.BitDepth / 8
+	frames := len(data) / c.src.frameSize()
+	// mono holds the previous sample followed by the new ones.
+	mono := make([]float64, frames+1)
+	mono[0] = c.prev
+	for i := 0; i < frames; i++ {
+		var sum float64
+		for ch := 0; ch < c.src.Channels; ch++ {
+			offset := (i*c.src.Channels + ch) * width
+			sum += decodeSample(data[offset:offset+width], c.src.BitDepth)
+		}
+		mono[i+1] = sum / float64(c.src.Channels)
+	}
+	if c.lowPass != nil {
+		c.lowPass.filter(mono[1:])
+	}
+
+	var out []byte
+	for ; c.pos <= float64(frames); c.pos += c.step {
+		i := int(c.pos)
+		v := mono[i]
+		if frac := c.pos - float64(i); frac > 0 && i < frames {
+			v += (mono[i+1] - v) * frac
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(int16(clamp16(v))))
+	}
+	c.pos -= float64(frames)
+	c.prev = mono[frames]
+	return out
+}
+
+// lowPassTaps is the length of the anti-aliasing filter. It delays the audio
+// by (lowPassTaps-1)/2 source samples, 0.65ms at 48kHz.
+const lowPassTaps = 63
+
+// lowPass is a Blackman windowed-sinc FIR filter.
+type lowPass struct {
+	taps    []float64
+	history []float64 // the last len(taps)-1 input samples
+}
+
+// newLowPass returns a filter of n taps passing the frequencies below cutoff,
+// a fraction of the sample rate between 0 and 0.5.
+func newLowPass(cutoff float64, n int) *lowPass {
+	taps := make([]float64, n)
+	var sum float64
+	for i := range taps {
+		x := float64(i) - float64(n-1)/2
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		window := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(n-1))
+		taps[i] = sinc * window
+		sum += taps[i]
+	}
+	// Unity gain at 0Hz.
+	for i := range taps {
+		taps[i] /= sum
+	}
+	return &lowPass{taps: taps, history: make([]float64, n-1)}
+}
+
+// filter filters samples in place, continuing the previous call.
+func (f *lowPass) filter(samples []float64) {
+	in := append(f.history, samples...)
+	for i := range samples {
+		var v float64
+		for j, tap := range f.taps {
+			v += tap * in[i+len(f.taps)-1-j]
+		}
+		samples[i] = v
+	}
+	f.history = append(f.history[:0], in[len(in)-len(f.history):]...)
+}
+
+// decodeSample returns a sample scaled to the 16 bit range.
+func decodeSample(b []byte, bitDepth int) float64 {
+	switch bitDepth {
+	case 8:
+		return float64(int(b[0])-128) * 256
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	case 24:
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)) / 65536
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 65536
+	}
+}
+
+func clamp16(v float64) float64 {
+	return min(max(v, -32768), 32767)
+}
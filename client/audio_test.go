@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
+)
+
+// sine returns d of a 440 Hz tone in format f, 16 bit.
+func sine(f AudioFormat, d time.Duration) []byte {
+	var pcm []byte
+	for i := 0; i < f.Bytes(d)/f.frameSize(); i++ {
+		v := int16(10000 * math.Sin(2*math.Pi*440*float64(i)/float64(f.SampleRate)))
+		for ch := 0; ch < f.Channels; ch++ {
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+		}
+	}
+	return pcm
+}
+
+func TestAudioStreamerConvertsWAV(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	stereo := AudioFormat{SampleRate: 48000, Channels: 2, BitDepth: 16}
+	wavData, _ := tools.Pcm2Wav(sine(stereo, time.Second), stereo.SampleRate, stereo.Channels, stereo.BitDepth)
+	s := NewAudioStreamer(c)
+	s.Unpaced, s.Commit, s.CreateResponse = true, true, true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.StreamWAV(ctx, bytes.NewReader(wavData)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.WaitFor(ctx, events.RealtimeClientEventResponseCreate); err != nil {
+		t.Fatal(err)
+	}
+
+	appends := srv.ReceivedOfType(events.RealtimeClientEventInputAudioBufferAppend)
+	if len(appends) != 10 {
+		t.Fatalf("got %d appends, want 10 frames of 100ms", len(appends))
+	}
+	for i, event := range appends {
+		pcm, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil || len(pcm) != 3200 {
+			t.Errorf("append %d has %d bytes (err %v), want 100ms of 16kHz mono", i, len(pcm), err)
+		}
+	}
+	if n := len(srv.ReceivedOfType(events.RealtimeClientEventInputAudioBufferCommit)); n != 1 {
+		t.Errorf("got %d commits, want 1", n)
+	}
+}
+
+func TestAudioStreamerPacing(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	s := NewAudioStreamer(c)
+	s.FrameDuration = 40 * time.Millisecond
+	start := time.Now()
+	if err := s.StreamPCM(context.Background(), bytes.NewReader(sine(InputPCMFormat, 200*time.Millisecond)), InputPCMFormat); err != nil {
+		t.Fatal(err)
+	}
+	// The fifth frame is sent 160ms after the first.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("200ms of audio streamed in %v, want real-time pace", elapsed)
+	}
+}
+
+func TestPCMConverterAntiAliasing(t *testing.T) {
+	src := AudioFormat{SampleRate: 48000, Channels: 1, BitDepth: 16}
+	// level returns the RMS of tone, converted to InputPCMFormat in chunks,
+	// relative to the source RMS.
+	level := func(freq float64) float64 {
+		var pcm []byte
+		for i := 0; i < src.SampleRate; i++ {
+			v := int16(10000 * math.Sin(2*math.Pi*freq*float64(i)/float64(src.SampleRate)))
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+		}
+		conv := newPCMConverter(src, InputPCMFormat)
+		var out []byte
+		for len(pcm) > 0 {
+			n := min(len(pcm), 4800)
+			out = append(out, conv.convert(pcm[:n])...)
+			pcm = pcm[n:]
+		}
+		samples := make([]int16, len(out)/2)
+		for i := range samples {
+			samples[i] = int16(binary.LittleEndian.Uint16(out[2*i:]))
+		}
+		// Skip the start of the filter.
+		return rms(samples[100:]) / (10000.0 / 32768 / math.Sqrt2)
+	}
+	if l := level(1000); l < 0.95 || l > 1.05 {
+		t.Errorf("1kHz tone at %.2f of its level, want it kept", l)
+	}
+	// 12kHz would alias to 4kHz at 16kHz.
+	if l := level(12000); l > 0.01 {
+		t.Errorf("12kHz tone at %.3f of its level, want it filtered", l)
+	}
+}
@@ -0,0 +1,344 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
+)
+
+// WAVWriter writes PCM audio as a WAV file. The header is written with a zero
+// size first and fixed up by Close, so w must be seekable.
+type WAVWriter struct {
+	w      io.WriteSeeker
+	format AudioFormat
+	size   int64
+}
+
+func NewWAVWriter(w io.WriteSeeker, format AudioFormat) (*WAVWriter, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	header, _ := tools.Pcm2Wav(nil, format.SampleRate, format.Channels, format.BitDepth)
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("write WAV header failed: %v", err)
+	}
+	return &WAVWriter{w: w, format: format}, nil
+}
+
+func (w *WAVWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close fixes up the sizes in the header and closes w if it is an io.Closer.
+func (w *WAVWriter) Close() error {
+	var sizes [4]byte
+	fixups := []struct {
+		offset int64
+		value  int64
+	}{{4, w.size + 36}, {40, w.size}}
+	for _, fixup := range fixups {
+		binary.LittleEndian.PutUint32(sizes[:], uint32(fixup.value))
+		if _, err := w.w.Seek(fixup.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("seek WAV header failed: %v", err)
+		}
+		if _, err := w.w.Write(sizes[:]); err != nil {
+			return fmt.Errorf("write WAV header failed: %v", err)
+		}
+	}
+	if _, err := w.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seek WAV end failed: %v", err)
+	}
+	if closer, ok := w.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// WAVFiles returns an AudioSink opener writing the audio of every response to
+// <dir>/<response id>.wav.
+func WAVFiles(dir string) func(responseID string, format AudioFormat) (io.Writer, error) {
+	return func(responseID string, format AudioFormat) (io.Writer, error) {
+		file, err := os.Create(filepath.Join(dir, responseID+".wav"))
+		if err != nil {
+			return nil, fmt.Errorf("create WAV file failed: %v", err)
+		}
+		w, err := NewWAVWriter(file, format)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return w, nil
+	}
+}
+
+// AudioPosition tells how much audio of a response has been played.
+type AudioPosition struct {
+	ResponseID   string
+	ItemID       string
+	ContentIndex int
+	// ItemMS is the audio played of ItemID, ResponseMS of the whole response.
+	ItemMS     int64
+	ResponseMS int64
+	// ResponseDone is set once response.done is received, while the rest of
+	// the audio is still being played.
+	ResponseDone bool
+}
+
+// PlayingWriter is implemented by AudioSink writers that play the audio
+// written to them, for example through a device with an output buffer. Played
+// returns the number of bytes written that have been heard so far. It is
+// called with the sink locked, so it must not call the sink, and keeps being
+// called after Close until the buffered audio is played.
+type PlayingWriter interface {
+	io.Writer
+	Played() int
+}
+
+// closedStreams is the number of finished or discarded responses remembered
+// by an AudioSink to drop their late deltas and answer PlayedMS.
+const closedStreams = 64
+
+type sinkStream struct {
+	w     io.Writer
+	bytes int
+	// items holds the items of the response in the order of their audio.
+	items []sinkItem
+	// done is set by response.done, which closes w.
+	done bool
+}
+
+type sinkItem struct {
+	id           string
+	contentIndex int
+	// start is the offset of the audio of the item in the response.
+	start int
+}
+
+// played returns the bytes of the response heard so far.
+func (st *sinkStream) played() int {
+	if w, ok := st.w.(PlayingWriter); ok {
+		return min(max(w.Played(), 0), st.bytes)
+	}
+	return st.bytes
+}
+
+// AudioSink writes the audio of response.audio.delta events, one writer per
+// response opened with Open. Writers implementing io.Closer are closed when
+// the response is done. The audio is PCM in Format, which follows the
+// output_audio_format of session.created and session.updated. Register Handle
+// as a handler, for example with Attach.
+//
+// The audio played is what a PlayingWriter reports, and all audio written for
+// other writers. A response stays current after response.done until its
+// PlayingWriter has played all of it.
+type AudioSink struct {
+	Open func(responseID string, format AudioFormat) (io.Writer, error)
+	// OnDone, if set, is called with the audio played of a response when
+	// response.done is received.
+	OnDone func(responseID string, playedMS int64)
+	// Format is OutputPCMFormat by default.
+	Format AudioFormat
+
+	lock    sync.Mutex
+	streams map[string]*sinkStream
+	// closed holds the audio played in milliseconds of the last closedStreams
+	// closed responses, closedOrder their IDs from the oldest.
+	closed      map[string]int64
+	closedOrder []string
+	current     string // response of the last delta
+	// unsupported is the output audio format of the session if not PCM.
+	unsupported string
+}
+
+func NewAudioSink(open func(responseID string, format AudioFormat) (io.Writer, error)) *AudioSink {
+	return &AudioSink{Open: open, Format: OutputPCMFormat, streams: make(map[string]*sinkStream), closed: make(map[string]int64)}
+}
+
+// Attach registers the sink on d and returns a function detaching it.
+func (s *AudioSink) Attach(d *Dispatcher) (detach func()) {
+	return d.OnAny(s.Handle)
+}
+
+// Handle applies a server event, other events than session and audio events
+// are ignored.
+func (s *AudioSink) Handle(event *events.Event) error {
+	switch event.Type {
+	case events.RealtimeServerEventSessionCreated, events.RealtimeServerEventSessionUpdated:
+		if event.Session == nil {
+			return nil
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		switch format := event.Session.OutputAudioFormat; format {
+		case "", "pcm":
+			s.unsupported = ""
+		default:
+			s.unsupported = format
+			return fmt.Errorf("unsupported output audio format %q", format)
+		}
+	case events.RealtimeServerEventResponseAudioDelta:
+		return s.write(event)
+	case events.RealtimeServerEventResponseDone:
+		if event.Response != nil {
+			return s.finish(event.Response.ID)
+		}
+	}
+	return nil
+}
+
+func (s *AudioSink) write(event *events.Event) error {
+	audio, err := base64.StdEncoding.DecodeString(event.Delta)
+	if err != nil {
+		return fmt.Errorf("decode audio delta failed: %v", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.unsupported != "" {
+		return fmt.Errorf("unsupported output audio format %q", s.unsupported)
+	}
+	if _, ok := s.closed[event.ResponseID]; ok {
+		// the response was finished or discarded
+		return nil
+	}
+	stream := s.streams[event.ResponseID]
+	if stream == nil {
+		w, err := s.Open(event.ResponseID, s.Format)
+		if err != nil {
+			return err
+		}
+		stream = &sinkStream{w: w}
+		s.streams[event.ResponseID] = stream
+	}
+	if stream.done {
+		return nil
+	}
+	if last := len(stream.items) - 1; last < 0 || stream.items[last].id != event.ItemID || stream.items[last].contentIndex != event.ContentIndex {
+		stream.items = append(stream.items, sinkItem{id: event.ItemID, contentIndex: event.ContentIndex, start: stream.bytes})
+	}
+	if s.current != event.ResponseID {
+		// The previous response is left playing only until audio follows.
+		if previous := s.streams[s.current]; previous != nil && previous.done {
+			s.forget(s.current, previous)
+		}
+		s.current = event.ResponseID
+	}
+	n, err := stream.w.Write(audio)
+	stream.bytes += n
+	if err != nil {
+		return fmt.Errorf("write audio failed: %v", err)
+	}
+	return nil
+}
+
+// closeWriter closes the writer of stream if it is an io.Closer.
+func closeWriter(stream *sinkStream) error {
+	if closer, ok := stream.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// forget drops the stream of a response, remembering it as closed. It must be
+// called with s.lock held.
+func (s *AudioSink) forget(responseID string, stream *sinkStream) {
+	delete(s.streams, responseID)
+	if s.current == responseID {
+		s.current = ""
+	}
+	s.closed[responseID] = s.ms(stream.played())
+	s.closedOrder = append(s.closedOrder, responseID)
+	if len(s.closedOrder) > closedStreams {
+		delete(s.closed, s.closedOrder[0])
+		s.closedOrder = s.closedOrder[1:]
+	}
+}
+
+func (s *AudioSink) finish(responseID string) error {
+	s.lock.Lock()
+	var err error
+	stream := s.streams[responseID]
+	if stream != nil && !stream.done {
+		stream.done = true
+		err = closeWriter(stream)
+		// The current response stays until it is played out.
+		if responseID != s.current || stream.played() >= stream.bytes {
+			s.forget(responseID, stream)
+		}
+	}
+	played, ok := s.closed[responseID]
+	if stream != nil {
+		played, ok = s.ms(stream.played()), true
+	}
+	s.lock.Unlock()
+	if ok && s.OnDone != nil {
+		s.OnDone(responseID, played)
+	}
+	return err
+}
+
+// Discard closes the writer of a response and drops its later deltas.
+func (s *AudioSink) Discard(responseID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.closed[responseID]; ok {
+		return nil
+	}
+	var err error
+	stream := s.streams[responseID]
+	if stream == nil {
+		stream = &sinkStream{w: io.Discard}
+	} else if !stream.done {
+		err = closeWriter(stream)
+	}
+	s.forget(responseID, stream)
+	return err
+}
+
+// PlayedMS returns the audio played of a response in milliseconds. Only the
+// last closedStreams finished or discarded responses are remembered, 0 is
+// returned for older ones.
+func (s *AudioSink) PlayedMS(responseID string) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if stream := s.streams[responseID]; stream != nil {
+		return s.ms(stream.played())
+	}
+	return s.closed[responseID]
+}
+
+// Current returns the position in the response being played, if any. A
+// finished response is current until its audio is played out.
+func (s *AudioSink) Current() (AudioPosition, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stream := s.streams[s.current]
+	if stream == nil {
+		return AudioPosition{}, false
+	}
+	played := stream.played()
+	if stream.done && played >= stream.bytes {
+		s.forget(s.current, stream)
+		return AudioPosition{}, false
+	}
+	position := AudioPosition{ResponseID: s.current, ResponseMS: s.ms(played), ResponseDone: stream.done}
+	for _, item := range stream.items {
+		if item.start > played {
+			break
+		}
+		position.ItemID, position.ContentIndex, position.ItemMS = item.id, item.contentIndex, s.ms(played-item.start)
+	}
+	return position, true
+}
+
+func (s *AudioSink) ms(n int) int64 {
+	return s.Format.Duration(n).Milliseconds()
+}
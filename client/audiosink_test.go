@@ -0,0 +1,159 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-audio/wav"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func audioDelta(responseID, itemID string, pcm []byte) *events.Event {
+	return &events.Event{
+		Type:       events.RealtimeServerEventResponseAudioDelta,
+		ResponseID: responseID,
+		ItemID:     itemID,
+		Delta:      base64.StdEncoding.EncodeToString(pcm),
+	}
+}
+
+func TestAudioSinkWritesWAV(t *testing.T) {
+	dir := t.TempDir()
+	played := make(map[string]int64)
+	sink := NewAudioSink(WAVFiles(dir))
+	sink.OnDone = func(responseID string, ms int64) { played[responseID] = ms }
+
+	half := sine(OutputPCMFormat, 500*time.Millisecond)
+	for _, event := range []*events.Event{
+		{Type: events.RealtimeServerEventSessionCreated, Session: &events.Session{OutputAudioFormat: "pcm"}},
+		audioDelta("resp_1", "item_1", half),
+		audioDelta("resp_1", "item_1", half),
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: "resp_1"}},
+	} {
+		if err := sink.Handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if played["resp_1"] != 1000 {
+		t.Errorf("played %dms, want 1000", played["resp_1"])
+	}
+
+	file, err := os.Open(filepath.Join(dir, "resp_1.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	buf, err := wav.NewDecoder(file).FullPCMBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Data) != OutputPCMFormat.SampleRate || buf.Format.SampleRate != OutputPCMFormat.SampleRate {
+		t.Errorf("WAV file has %d samples at %dHz, want 1s at %dHz", len(buf.Data), buf.Format.SampleRate, OutputPCMFormat.SampleRate)
+	}
+}
+
+func TestAudioSinkDiscard(t *testing.T) {
+	var written int
+	sink := NewAudioSink(func(string, AudioFormat) (io.Writer, error) {
+		return writerFunc(func(p []byte) (int, error) {
+			written += len(p)
+			return len(p), nil
+		}), nil
+	})
+	chunk := sine(OutputPCMFormat, 200*time.Millisecond)
+	_ = sink.Handle(audioDelta("resp_1", "item_1", chunk))
+	position, ok := sink.Current()
+	if !ok || position.ItemID != "item_1" || position.ItemMS != 200 {
+		t.Fatalf("Current() = %+v, %v", position, ok)
+	}
+	if err := sink.Discard("resp_1"); err != nil {
+		t.Fatal(err)
+	}
+	_ = sink.Handle(audioDelta("resp_1", "item_1", chunk))
+	if written != len(chunk) {
+		t.Errorf("wrote %d bytes, want the deltas after Discard dropped", written)
+	}
+	if _, ok := sink.Current(); ok {
+		t.Errorf("discarded response still current")
+	}
+	if err := sink.Handle(&events.Event{Type: events.RealtimeServerEventSessionUpdated, Session: &events.Session{OutputAudioFormat: "mp3"}}); err == nil {
+		t.Errorf("unsupported output audio format accepted")
+	}
+}
+
+func TestAudioSinkForgetsClosedResponses(t *testing.T) {
+	opened := make(map[string]int)
+	sink := NewAudioSink(func(responseID string, _ AudioFormat) (io.Writer, error) {
+		opened[responseID]++
+		return io.Discard, nil
+	})
+	chunk := sine(OutputPCMFormat, 100*time.Millisecond)
+	for i := range closedStreams + 10 {
+		id := fmt.Sprintf("resp_%d", i)
+		for _, event := range []*events.Event{
+			audioDelta(id, "item", chunk),
+			{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: id}},
+			// A late delta does not open the response again.
+			audioDelta(id, "item", chunk),
+		} {
+			if err := sink.Handle(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if opened[id] != 1 {
+			t.Fatalf("%s opened %d times", id, opened[id])
+		}
+	}
+	if len(sink.streams) != 0 || len(sink.closed) != closedStreams || len(sink.closedOrder) != closedStreams {
+		t.Errorf("%d streams and %d closed responses kept, want 0 and %d", len(sink.streams), len(sink.closed), closedStreams)
+	}
+	last := fmt.Sprintf("resp_%d", closedStreams+9)
+	if got := sink.PlayedMS(last); got != 100 {
+		t.Errorf("PlayedMS(%s) = %d, want 100", last, got)
+	}
+	if got := sink.PlayedMS("resp_0"); got != 0 {
+		t.Errorf("PlayedMS of a forgotten response = %d, want 0", got)
+	}
+}
+
+func TestAudioSinkPlaybackPosition(t *testing.T) {
+	speaker := &player{}
+	sink := NewAudioSink(func(string, AudioFormat) (io.Writer, error) { return speaker, nil })
+	chunk := sine(OutputPCMFormat, 200*time.Millisecond)
+	for _, event := range []*events.Event{
+		audioDelta("resp_1", "item_1", chunk),
+		audioDelta("resp_1", "item_1", chunk),
+	} {
+		if err := sink.Handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The position follows the audio heard, not the audio received.
+	speaker.played.Store(int64(len(sine(OutputPCMFormat, 100*time.Millisecond))))
+	if position, ok := sink.Current(); !ok || position.ItemMS != 100 || position.ResponseDone {
+		t.Errorf("Current() = %+v, %v, want 100ms into item_1", position, ok)
+	}
+
+	_ = sink.Handle(&events.Event{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: "resp_1"}})
+	if position, ok := sink.Current(); !ok || !position.ResponseDone {
+		t.Errorf("Current() = %+v, %v, want the finished response still playing", position, ok)
+	}
+	speaker.played.Store(int64(2 * len(chunk)))
+	if position, ok := sink.Current(); ok {
+		t.Errorf("Current() = %+v after the response was played out", position)
+	}
+	if len(sink.streams) != 0 || sink.PlayedMS("resp_1") != 400 {
+		t.Errorf("played out response kept: %d streams, PlayedMS %d", len(sink.streams), sink.PlayedMS("resp_1"))
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// ackTypes maps client events to the server event acknowledging them.
+var ackTypes = map[events.EventType]events.EventType{
+	events.RealtimeClientEventSessionUpdate:              events.RealtimeServerEventSessionUpdated,
+	events.RealtimeClientEventTranscriptionSessionUpdate: events.RealtimeServerEventTranscriptionSessionUpdated,
+	events.RealtimeClientEventInputAudioBufferCommit:     events.RealtimeServerEventInputAudioBufferCommitted,
+	events.RealtimeClientEventInputAudioBufferClear:      events.RealtimeServerEventInputAudioBufferCleared,
+	events.RealtimeClientEventConversationItemCreate:     events.RealtimeServerEventConversationItemCreated,
+	events.RealtimeClientEventConversationItemRetrieve:   events.RealtimeServerEventConversationItemRetrieved,
+	events.RealtimeClientEventConversationItemTruncate:   events.RealtimeServerEventConversationItemTruncated,
+	events.RealtimeClientEventConversationItemDelete:     events.RealtimeServerEventConversationItemDeleted,
+	events.RealtimeClientEventResponseCreate:             events.RealtimeServerEventResponseCreated,
+}
+
+type pendingAck struct {
+	eventID string
+	ackType events.EventType
+	itemID  string
+	result  chan *events.Event
+}
+
+// pendingAcks holds the SendAndAwait calls waiting for their acknowledgement.
+// Acknowledgements are matched in the order the events were sent.
+type pendingAcks struct {
+	lock    sync.Mutex
+	waiters []*pendingAck
+}
+
+func (p *pendingAcks) add(w *pendingAck) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.waiters = append(p.waiters, w)
+}
+
+func (p *pendingAcks) remove(w *pendingAck) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i := range p.waiters {
+		if p.waiters[i] == w {
+			p.waiters = append(p.waiters[:i:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// resolve hands event to the first waiter it acknowledges or fails.
+func (p *pendingAcks) resolve(event *events.Event) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, w := range p.waiters {
+		if !w.matches(event) {
+			continue
+		}
+		p.waiters = append(p.waiters[:i:i], p.waiters[i+1:]...)
+		w.result <- event
+		return
+	}
+}
+
+func (w *pendingAck) matches(event *events.Event) bool {
+	if event.Type == events.RealtimeServerEventError {
+		return event.Error != nil && event.Error.EventID != "" && event.Error.EventID == w.eventID
+	}
+	if event.Type != w.ackType {
+		return false
+	}
+	return w.itemID == "" || itemIDOf(event) == "" || itemIDOf(event) == w.itemID
+}
+
+func itemIDOf(event *events.Event) string {
+	if event.ItemID != "" {
+		return event.ItemID
+	}
+	if event.Item != nil {
+		return event.Item.ID
+	}
+	return ""
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// SendAndAwait sends event and waits for the server event acknowledging it,
+// for example session.updated for session.update. An event_id is generated
+// if event has none. A server error event referencing the event_id is
+// returned as *ServerError. Acknowledgements are matched by the read loop
+// before the handlers run, so SendAndAwait may be called from a handler; the
+// events received meanwhile are handled once the handler returns. For the same
+// reason the handlers of the acknowledgement may not have run yet when
+// SendAndAwait returns.
+func (r *realtimeClient) SendAndAwait(ctx context.Context, event *events.Event) (*events.Event, error) {
+	ackType, ok := ackTypes[event.Type]
+	if !ok {
+		return nil, fmt.Errorf("no acknowledgement known for event type %s", event.Type)
+	}
+	r.lock.RLock()
+	sessionCtx := r.ctx
+	r.lock.RUnlock()
+	if sessionCtx == nil {
+		return nil, ErrNotConnected
+	}
+	if event.EventID == "" {
+		event.EventID = newEventID()
+	}
+
+	w := &pendingAck{eventID: event.EventID, ackType: ackType, itemID: itemIDOf(event), result: make(chan *events.Event, 1)}
+	r.pending.add(w)
+	defer r.pending.remove(w)
+	if err := r.SendContext(ctx, event); err != nil {
+		return nil, err
+	}
+
+	select {
+	case ack := <-w.result:
+		if ack.Type == events.RealtimeServerEventError {
+			return nil, newServerError(ack)
+		}
+		return ack, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	case <-sessionCtx.Done():
+		return nil, context.Cause(sessionCtx)
+	}
+}
+
+// UpdateSession sends session.update and returns the session of session.updated.
+func (r *realtimeClient) UpdateSession(ctx context.Context, session *events.Session) (*events.Session, error) {
+	ack, err := r.SendAndAwait(ctx, &events.Event{Type: events.RealtimeClientEventSessionUpdate, Session: session})
+	if err != nil {
+		return nil, err
+	}
+	return ack.Session, nil
+}
+
+// CreateItem sends conversation.item.create and returns the created item.
+func (r *realtimeClient) CreateItem(ctx context.Context, previousItemID string, item *events.Item) (*events.Item, error) {
+	ack, err := r.SendAndAwait(ctx, &events.Event{
+		Type:           events.RealtimeClientEventConversationItemCreate,
+		PreviousItemID: previousItemID,
+		Item:           item,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ack.Item, nil
+}
+
+// CommitAudio sends input_audio_buffer.commit and returns the id of the user
+// item created from the buffer.
+func (r *realtimeClient) CommitAudio(ctx context.Context) (itemID string, err error) {
+	ack, err := r.SendAndAwait(ctx, &events.Event{Type: events.RealtimeClientEventInputAudioBufferCommit})
+	if err != nil {
+		return "", err
+	}
+	return ack.ItemID, nil
+}
+
+// DeleteItem sends conversation.item.delete and waits until it is deleted.
+func (r *realtimeClient) DeleteItem(ctx context.Context, itemID string) error {
+	_, err := r.SendAndAwait(ctx, &events.Event{Type: events.RealtimeClientEventConversationItemDelete, ItemID: itemID})
+	return err
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestSendAndAwait(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session, err := c.UpdateSession(ctx, &events.Session{Instructions: "be brief"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Instructions != "be brief" {
+		t.Errorf("session.updated instructions = %q", session.Instructions)
+	}
+
+	item, err := c.CreateItem(ctx, "", &events.Item{ID: "item_user", Type: events.ItemTypeMessage, Role: events.ItemRoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ID != "item_user" {
+		t.Errorf("created item id = %q", item.ID)
+	}
+
+	srv.RejectNext(events.RealtimeClientEventConversationItemDelete, events.EventError{
+		Type: "invalid_request_error", Code: "item_not_found", Message: "no such item", Param: "item_id",
+	})
+	err = c.DeleteItem(ctx, "item_missing")
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.Code != "item_not_found" {
+		t.Fatalf("DeleteItem error = %v, want ServerError item_not_found", err)
+	}
+	sent := srv.ReceivedOfType(events.RealtimeClientEventConversationItemDelete)
+	if len(sent) != 1 || sent[0].EventID == "" || sent[0].EventID != serverErr.EventID {
+		t.Errorf("error not correlated with event_id, sent: %+v", sent)
+	}
+}
+
+func TestSendAndAwaitInHandler(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	results := make(chan error, 1)
+	var updated []string
+	c.OnSessionUpdated(func(event *events.Event) error {
+		updated = append(updated, event.Session.Instructions)
+		return nil
+	})
+	c.OnSessionCreated(func(*events.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		session, err := c.UpdateSession(ctx, &events.Session{Instructions: "from handler"})
+		if err == nil && session.Instructions != "from handler" {
+			err = fmt.Errorf("session.updated instructions = %q", session.Instructions)
+		}
+		// The session.updated handler runs after this one returns.
+		if err == nil && len(updated) != 0 {
+			err = errors.New("handlers ran while a handler was running")
+		}
+		results <- err
+		return nil
+	})
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	if err := <-results; err != nil {
+		t.Errorf("UpdateSession() in handler = %v", err)
+	}
+}
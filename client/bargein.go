@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// BargeIn handles the user interrupting the assistant. When the server
+// detects speech while the sink is playing a response, BargeIn drops the rest
+// of the response's audio, cancels the response and truncates the playing
+// item to the audio actually played, so that the conversation holds what
+// the user heard. The audio played is only known if the writers of the sink
+// implement PlayingWriter; otherwise the audio received counts as played and
+// the truncation point may be late.
+type BargeIn struct {
+	// OnInterrupt, if set, is called after an interruption, for example to
+	// flush the audio buffered by the player.
+	OnInterrupt func(position AudioPosition)
+
+	client RealtimeClient
+	sink   *AudioSink
+}
+
+func NewBargeIn(c RealtimeClient, sink *AudioSink) *BargeIn {
+	return &BargeIn{client: c, sink: sink}
+}
+
+// Attach registers the controller on d and returns a function detaching it.
+func (b *BargeIn) Attach(d *Dispatcher) (detach func()) {
+	return d.OnSpeechStarted(b.Handle)
+}
+
+// Handle interrupts the playing response on input_audio_buffer.speech_started,
+// other events are ignored.
+func (b *BargeIn) Handle(event *events.Event) error {
+	if event.Type != events.RealtimeServerEventInputAudioBufferSpeechStarted {
+		return nil
+	}
+	return b.Interrupt(context.Background())
+}
+
+// Interrupt stops the response being played, if any. A response already done
+// on the server is not cancelled, only its playback and item are cut.
+func (b *BargeIn) Interrupt(ctx context.Context) error {
+	position, ok := b.sink.Current()
+	if !ok {
+		return nil
+	}
+	// Discard first, so that no delta arriving meanwhile is played.
+	if err := b.sink.Discard(position.ResponseID); err != nil {
+		return fmt.Errorf("discard audio of %s failed: %w", position.ResponseID, err)
+	}
+	if !position.ResponseDone {
+		err := b.client.SendContext(ctx, &events.Event{
+			Type:       events.RealtimeClientEventResponseCancel,
+			ResponseID: position.ResponseID,
+		})
+		if err != nil {
+			return fmt.Errorf("cancel response %s failed: %w", position.ResponseID, err)
+		}
+	}
+	if position.ItemID != "" {
+		err := b.client.SendContext(ctx, &events.Event{
+			Type:         events.RealtimeClientEventConversationItemTruncate,
+			ItemID:       position.ItemID,
+			ContentIndex: position.ContentIndex,
+			AudioEndMS:   position.ItemMS,
+		})
+		if err != nil {
+			return fmt.Errorf("truncate item %s failed: %w", position.ItemID, err)
+		}
+	}
+	if b.OnInterrupt != nil {
+		b.OnInterrupt(position)
+	}
+	return nil
+}
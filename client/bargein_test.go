@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestBargeInTruncatesPlayedAudio(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	sink := NewAudioSink(func(string, AudioFormat) (io.Writer, error) { return io.Discard, nil })
+	done := make(chan int64, 1)
+	sink.OnDone = func(_ string, playedMS int64) { done <- playedMS }
+	sink.Attach(c.Dispatcher)
+	interrupted := make(chan AudioPosition, 1)
+	bargeIn := NewBargeIn(c, sink)
+	bargeIn.OnInterrupt = func(position AudioPosition) { interrupted <- position }
+	bargeIn.Attach(c.Dispatcher)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	chunk := sine(OutputPCMFormat, 150*time.Millisecond)
+	for _, event := range []*events.Event{
+		audioDelta("resp_1", "item_1", chunk),
+		audioDelta("resp_1", "item_1", chunk),
+		{Type: events.RealtimeServerEventInputAudioBufferSpeechStarted},
+		audioDelta("resp_1", "item_1", chunk),
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: "resp_1", Status: events.ResponseStatusCancelled}},
+	} {
+		if err := srv.Send(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	truncate, err := srv.WaitFor(ctx, events.RealtimeClientEventConversationItemTruncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncate.ItemID != "item_1" || truncate.AudioEndMS != 300 {
+		t.Errorf("truncate item %s at %dms, want item_1 at 300ms", truncate.ItemID, truncate.AudioEndMS)
+	}
+	cancels := srv.ReceivedOfType(events.RealtimeClientEventResponseCancel)
+	if len(cancels) != 1 || cancels[0].ResponseID != "resp_1" {
+		t.Errorf("response.cancel events: %v", cancels)
+	}
+	select {
+	case position := <-interrupted:
+		if position.ResponseID != "resp_1" {
+			t.Errorf("interrupted %s", position.ResponseID)
+		}
+	case <-ctx.Done():
+		t.Fatal("OnInterrupt not called")
+	}
+	select {
+	case played := <-done:
+		if played != 300 {
+			t.Errorf("played %dms, want the delta after the interruption dropped", played)
+		}
+	case <-ctx.Done():
+		t.Fatal("response.done not handled")
+	}
+}
+
+// player is a PlayingWriter whose playback position is set by the test.
+type player struct {
+	played atomic.Int64
+}
+
+func (p *player) Write(b []byte) (int, error) { return len(b), nil }
+
+func (p *player) Played() int { return int(p.played.Load()) }
+
+func TestBargeInAfterResponseDone(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	speaker := &player{}
+	sink := NewAudioSink(func(string, AudioFormat) (io.Writer, error) { return speaker, nil })
+	done := make(chan int64, 1)
+	sink.OnDone = func(_ string, playedMS int64) { done <- playedMS }
+	sink.Attach(c.Dispatcher)
+	interrupted := make(chan AudioPosition, 1)
+	bargeIn := NewBargeIn(c, sink)
+	bargeIn.OnInterrupt = func(position AudioPosition) { interrupted <- position }
+	bargeIn.Attach(c.Dispatcher)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	// The whole response arrives before the user heard half of it.
+	chunk := sine(OutputPCMFormat, 300*time.Millisecond)
+	for _, event := range []*events.Event{
+		audioDelta("resp_1", "item_1", chunk),
+		audioDelta("resp_1", "item_2", chunk),
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: "resp_1", Status: events.ResponseStatusCompleted}},
+	} {
+		if err := srv.Send(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("response.done not handled")
+	}
+	speaker.played.Store(int64(len(sine(OutputPCMFormat, 450*time.Millisecond))))
+	if err := srv.Send(&events.Event{Type: events.RealtimeServerEventInputAudioBufferSpeechStarted}); err != nil {
+		t.Fatal(err)
+	}
+
+	truncate, err := srv.WaitFor(ctx, events.RealtimeClientEventConversationItemTruncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncate.ItemID != "item_2" || truncate.AudioEndMS != 150 {
+		t.Errorf("truncate item %s at %dms, want item_2 at 150ms", truncate.ItemID, truncate.AudioEndMS)
+	}
+	if cancels := srv.ReceivedOfType(events.RealtimeClientEventResponseCancel); len(cancels) != 0 {
+		t.Errorf("finished response cancelled: %v", cancels)
+	}
+	select {
+	case position := <-interrupted:
+		if !position.ResponseDone || position.ResponseMS != 450 {
+			t.Errorf("interrupted at %+v, want 450ms into the finished response", position)
+		}
+	case <-ctx.Done():
+		t.Fatal("OnInterrupt not called")
+	}
+	if got := sink.PlayedMS("resp_1"); got != 450 {
+		t.Errorf("PlayedMS() = %d, want 450", got)
+	}
+}
@@ -1,184 +1,623 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/MetaGLM/glm-realtime-sdk/golang/events"
-	"github.com/MetaGLM/glm-realtime-sdk/golang/tools"
 	"github.com/gorilla/websocket"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
 )
 
+// RealtimeClient is a client of the GLM-Realtime WebSocket API. The *Context
+// variants honor cancellation and deadlines of the given context; the plain
+// methods are equivalent to calling them with context.Background().
 type RealtimeClient interface {
 	Connect() error
+	ConnectContext(ctx context.Context) error
 	Disconnect() error
 	Send(event *events.Event) error
-	Wait()
+	SendContext(ctx context.Context, event *events.Event) error
+	Wait() error
+	WaitContext(ctx context.Context) error
 }
 
 type realtimeClient struct {
+	*Dispatcher
+
 	url, apiKey string
-	onReceived  func(event *events.Event) error
 	conn        *websocket.Conn
 
-	isConnected bool
-	lock        sync.RWMutex
-	wg          *sync.WaitGroup
+	dialer         *websocket.Dialer
+	dialerOpts     []func(d *websocket.Dialer)
+	header         http.Header
+	maxMessageSize int64
+
+	reconnect     *ReconnectPolicy
+	onStateChange func(state ConnState, err error)
+	// lastSessionUpdate is replayed after a reconnect.
+	lastSessionUpdate atomic.Pointer[events.Event]
+
+	queueSize   int
+	queuePolicy QueueFullPolicy
+	priorities  map[events.EventType]Priority
+	outbox      *outbox
+	pending     pendingAcks
+	limits      rateLimits
+	paramSets   parameterSets
+	extractor   tools.FrameExtractor
+	tools       *toolRunner
+	recorder    *Recorder
+	logger      *slog.Logger
+	// sessionID is the id of the server session, added to the log records.
+	sessionID atomic.Pointer[string]
+
+	pingInterval, pongTimeout time.Duration
+	idleTimeout               time.Duration
+	sessionTimeout            time.Duration
+	stallAfter                time.Duration
+	onStall                   func(idle time.Duration)
+	// lastEventAt is the unix milli time of the last server event.
+	lastEventAt atomic.Int64
+
+	state ConnState
+	lock  sync.RWMutex
+	wg    *sync.WaitGroup
+	// ctx is the session context, its cause tells why the session ended.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
 }
 
-const waitTimeout = 30 * time.Second // Define a default timeout for wait
+// errClientClosed is the cause recorded when the session is ended by Disconnect.
+var errClientClosed = errors.New("disconnected by client")
 
-func NewRealtimeClient(url, apiKey string, onReceived func(event *events.Event) error) *realtimeClient {
-	return &realtimeClient{url: url, apiKey: apiKey, onReceived: onReceived}
+// NewRealtimeClient creates a client. Server events are delivered to the
+// handlers registered on the embedded Dispatcher; onReceived, if not nil, is
+// registered as the first catch-all handler.
+func NewRealtimeClient(url, apiKey string, onReceived func(event *events.Event) error, opts ...Option) *realtimeClient {
+	dialer := *websocket.DefaultDialer
+	r := &realtimeClient{
+		Dispatcher:   NewDispatcher(),
+		url:          url,
+		apiKey:       apiKey,
+		dialer:       &dialer,
+		header:       make(http.Header),
+		priorities:   make(map[events.EventType]Priority),
+		pingInterval: defaultPingInterval,
+		pongTimeout:  defaultPongTimeout,
+		extractor:    tools.NewFFmpegExtractor(),
+	}
+	for t, p := range defaultPriorities {
+		r.priorities[t] = p
+	}
+	if onReceived != nil {
+		r.OnAny(onReceived)
+	}
+	r.tools = newToolRunner(r)
+	for _, opt := range opts {
+		opt(r)
+	}
+	for _, fn := range r.dialerOpts {
+		fn(r.dialer)
+	}
+	return r
 }
 
 func (r *realtimeClient) Connect() error {
+	return r.ConnectContext(context.Background())
+}
+
+// ConnectContext dials the server. The deadline of ctx only bounds the dial,
+// so a ctx with a timeout may be used to limit the connect time. Cancelling
+// ctx also ends the session: the connection is closed and Wait returns the
+// cause.
+func (r *realtimeClient) ConnectContext(ctx context.Context) error {
 	r.lock.Lock()
-	defer r.lock.Unlock()
-	if r.isConnected {
+	if r.state != StateClosed {
+		r.lock.Unlock()
 		return nil
 	}
-	var header http.Header
+	r.state = StateConnecting
+	r.lock.Unlock()
+	r.notifyState(StateConnecting, nil)
+
+	c, err := r.dial(ctx)
+	r.lock.Lock()
+	if err == nil && r.state != StateConnecting {
+		// Disconnect was called while dialing.
+		_ = c.Close()
+		err = errClientClosed
+	}
+	if err != nil {
+		r.state = StateClosed
+		r.lock.Unlock()
+		r.notifyState(StateClosed, err)
+		return err
+	}
+	r.conn, r.state, r.wg = c, StateConnected, &sync.WaitGroup{}
+	r.ctx, r.cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+	sessionCtx := r.ctx
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			r.endSession(sessionCtx, context.Cause(ctx))
+		}
+	})
+	context.AfterFunc(sessionCtx, func() { stop() })
+	r.outbox = newOutbox(r.queueSize, r.queuePolicy)
+	r.paramSets.reset()
+	r.lastEventAt.Store(time.Now().UnixMilli())
+	if r.sessionTimeout > 0 {
+		timeout := r.sessionTimeout
+		timer := time.AfterFunc(timeout, func() {
+			r.endSession(sessionCtx, fmt.Errorf("session timed out after %v", timeout))
+		})
+		context.AfterFunc(sessionCtx, func() { timer.Stop() })
+	}
+	queue := newEventQueue()
+	r.wg.Add(2)
+	go r.run(r.ctx, c, queue)
+	go r.handleEvents(r.ctx, queue)
+	go r.monitor(r.ctx)
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		r.endSession(ctx, context.Cause(ctx))
+	}(r.ctx)
+	r.lock.Unlock()
+	r.notifyState(StateConnected, nil)
+
+	return nil
+}
+
+func (r *realtimeClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	header := r.header.Clone()
 	if r.apiKey != "" {
-		header = make(http.Header)
 		header.Set("Authorization", fmt.Sprintf("Bearer %s", r.apiKey))
 	}
-	c, rsp, err := websocket.DefaultDialer.Dial(r.url, header)
+	c, rsp, err := r.dialer.DialContext(ctx, r.url, header)
 	if err != nil {
-		log.Printf("[RealtimeClient] WebSocket dial fail, url: %s, rsp: %v, err: %v\n", r.url, rsp, err)
-		return err
+		r.log().Error("WebSocket dial failed", "url", r.url, "status", responseStatus(rsp), "err", err)
+		// The dialer reports an expired ctx as an i/o timeout, possibly
+		// before ctx itself is done.
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, cause
+		}
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return nil, context.DeadlineExceeded
+		}
+		if errors.Is(err, websocket.ErrBadHandshake) && rsp != nil {
+			return nil, &HandshakeError{StatusCode: rsp.StatusCode, Err: err}
+		}
+		return nil, err
+	}
+	if r.maxMessageSize > 0 {
+		c.SetReadLimit(r.maxMessageSize)
 	}
+	c.EnableWriteCompression(r.dialer.EnableCompression)
 	c.SetCloseHandler(func(code int, reason string) error {
-		log.Printf("[RealtimeClient] WebSocket closed with code: %d, reason: %s\n", code, reason)
+		r.log().Info("WebSocket closed by server", "code", code, "reason", reason)
 		return nil
 	})
-	r.conn, r.isConnected, r.wg = c, true, &sync.WaitGroup{}
-
-	r.wg.Add(1)
-	go r.readWsMsg()
-
-	return nil
+	c.SetPongHandler(func(string) error {
+		return r.extendReadDeadline(c)
+	})
+	return c, nil
 }
 
 func (r *realtimeClient) IsConnected() bool {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
-	return r.isConnected
+	return r.state == StateConnected
+}
+
+// State returns the current connection state.
+func (r *realtimeClient) State() ConnState {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.state
+}
+
+// notifyState must be called without r.lock held, so that the handler may use the client.
+func (r *realtimeClient) notifyState(state ConnState, err error) {
+	if r.onStateChange != nil {
+		r.onStateChange(state, err)
+	}
 }
 
 func (r *realtimeClient) Disconnect() (err error) {
+	return r.endSession(nil, errClientClosed)
+}
+
+// endSession records cause as the reason the session ended and closes the
+// connection. If session is not nil, it only ends that session.
+func (r *realtimeClient) endSession(session context.Context, cause error) error {
 	r.lock.Lock()
-	defer r.lock.Unlock()
-	if !r.isConnected {
+	// A session context belongs to a previous session if a new one is
+	// connecting.
+	if session != nil && (session != r.ctx || r.state == StateConnecting) {
+		r.lock.Unlock()
+		return nil
+	}
+	switch r.state {
+	case StateClosed:
+		r.lock.Unlock()
+		return nil
+	case StateConnecting:
+		// ConnectContext notices the state change once the dial returns.
+		r.state = StateClosed
+		r.lock.Unlock()
 		return nil
 	}
-	r.isConnected = false
-	return r.conn.Close()
+	r.state = StateClosed
+	r.cancel(cause)
+	cause = context.Cause(r.ctx)
+	r.outbox.close(cause)
+	err := r.conn.Close()
+	r.lock.Unlock()
+	r.notifyState(StateClosed, cause)
+	return err
 }
 
-func (r *realtimeClient) Wait() {
-	log.Printf("[RealtimeClient] Waiting for exit with timeout %v ...\n", waitTimeout)
+func (r *realtimeClient) Wait() error {
+	r.log().Debug("Waiting for exit")
+	return r.WaitContext(context.Background())
+}
+
+// WaitContext blocks until the session ends or ctx is done. It returns nil if
+// the session was ended by Disconnect, otherwise the reason it ended.
+func (r *realtimeClient) WaitContext(ctx context.Context) error {
+	r.lock.RLock()
+	wg, sessionCtx := r.wg, r.ctx
+	r.lock.RUnlock()
+	if wg == nil {
+		return ErrNotConnected
+	}
 
 	done := make(chan struct{})
 	go func() {
 		defer close(done) // Ensure channel is closed when Wait() returns
-		r.wg.Wait()
+		wg.Wait()
 	}()
 
 	select {
 	case <-done:
-		log.Printf("[RealtimeClient] Exited normally.")
-	case <-time.After(waitTimeout):
-		log.Printf("[RealtimeClient] Wait timed out after %v.", waitTimeout)
-		// Consider adding further action if timeout occurs, e.g., cancelling context or returning an error
+		err := context.Cause(sessionCtx)
+		if errors.Is(err, errClientClosed) {
+			r.log().Info("Exited normally")
+			return nil
+		}
+		r.log().Warn("Exited", "reason", err)
+		return err
+	case <-ctx.Done():
+		err := context.Cause(ctx)
+		r.log().Info("Wait interrupted", "err", err)
+		return err
 	}
 }
 
 func (r *realtimeClient) Send(event *events.Event) (err error) {
+	return r.SendContext(context.Background(), event)
+}
+
+// SendContext queues event for the write pump and waits until it is written.
+// If ctx is done first, SendContext returns and the event is skipped unless
+// its write has already started. While reconnecting events stay queued.
+// Throttled events wait for or fail on exhausted rate limits, see
+// WithRateLimitThrottle.
+func (r *realtimeClient) SendContext(ctx context.Context, event *events.Event) (err error) {
 	r.lock.RLock()
-	defer r.lock.RUnlock()
-	if !r.isConnected {
-		log.Printf("[RealtimeClient] Sending event fail, err: not connected\n")
-		return fmt.Errorf("not connected")
+	state, out := r.state, r.outbox
+	r.lock.RUnlock()
+	if state != StateConnected && state != StateReconnecting {
+		r.log().Warn("Send failed", "type", event.Type, "err", ErrNotConnected)
+		return ErrNotConnected
+	}
+	if err = r.limits.throttle(ctx, event.Type); err != nil {
+		r.log().Warn("Send failed", "type", event.Type, "event_id", event.EventID, "err", err)
+		return err
 	}
 	if event.ClientTimestamp <= 0 {
 		event.ClientTimestamp = time.Now().UnixMilli()
 	}
-	if err = r.conn.WriteMessage(websocket.TextMessage, []byte(event.ToJson())); err != nil {
-		log.Printf("[RealtimeClient] Send failed, error: %v\n", err)
+	msg := &outboundMsg{
+		ctx:      ctx,
+		event:    event,
+		data:     []byte(event.ToJson()),
+		priority: r.priorities[event.Type],
+		done:     make(chan error, 1),
+	}
+	if err = out.push(ctx, msg); err == nil {
+		select {
+		case err = <-msg.done:
+		case <-ctx.Done():
+			err = context.Cause(ctx)
+		}
+	}
+	if err != nil {
+		r.log().Warn("Send failed", "type", event.Type, "event_id", event.EventID, "err", err)
 	}
 	return err
 }
 
-func (r *realtimeClient) SendFrameByVideo(event *events.Event) (err error) {
+// SendFrameByVideo extracts the frames of the H.264 Annex-B video in
+// event.VideoFrame and sends each as a copy of event. The SPS and PPS are
+// taken from the stream and cached for the session, unless set with
+// WithH264ParameterSets; video arriving before them fails with
+// ErrMissingParameterSets. The frames are extracted by the FrameExtractor set
+// with WithFrameExtractor, ffmpeg by default.
+func (r *realtimeClient) SendFrameByVideo(event *events.Event) error {
+	return r.SendFrameByVideoContext(context.Background(), event)
+}
+
+// SendFrameByVideoContext is SendFrameByVideo with a context bounding the
+// frame extraction and the sends.
+func (r *realtimeClient) SendFrameByVideoContext(ctx context.Context, event *events.Event) (err error) {
 	if events.RealtimeClientVideoAppend != event.Type {
 		return fmt.Errorf("event type is not RealtimeClientVideoAppend")
 	}
 	if event.VideoFrame == nil {
 		return fmt.Errorf("event videoFrame is nil")
 	}
-
-	r.lock.RLock()
-	defer r.lock.RUnlock()
-	if !r.isConnected {
-		log.Printf("[RealtimeClient] Sending event fail, err: not connected\n")
-		return fmt.Errorf("not connected")
+	if !r.IsConnected() {
+		r.log().Warn("Send failed", "type", event.Type, "err", ErrNotConnected)
+		return ErrNotConnected
 	}
 	if event.ClientTimestamp <= 0 {
 		event.ClientTimestamp = time.Now().UnixMilli()
 	}
-	frames, err := tools.ExtractFramesToBase64(event.VideoFrame, "Z0LADJoFAAABMA==", "aM48gA==")
+	sps, pps, err := r.paramSets.observe(event.VideoFrame)
+	if err != nil {
+		return err
+	}
+	video, err := tools.InjectSPSPPS(event.VideoFrame, sps, pps)
+	if err != nil {
+		return err
+	}
+	frames, err := r.extractor.ExtractFrames(ctx, video)
 	if err != nil {
-		return fmt.Errorf("extract frames failed: %v", err)
+		return fmt.Errorf("extract frames failed: %w", err)
 	}
 	for index := range frames {
-		event.VideoFrame = frames[index]
-		if err = r.conn.WriteMessage(websocket.TextMessage, []byte(event.ToJson())); err != nil {
-			log.Printf("[RealtimeClient] Send failed, error: %v\n", err)
+		frame := *event
+		frame.VideoFrame = frames[index]
+		if err = r.SendContext(ctx, &frame); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *realtimeClient) readWsMsg() {
+// writePump is the only writer of conn. It stops when ctx is done or a write
+// fails, in which case conn is closed so that the read loop notices.
+func (r *realtimeClient) writePump(ctx context.Context, conn *websocket.Conn, out *outbox) {
+	for {
+		msg, err := out.pop(ctx)
+		if err != nil {
+			return
+		}
+		if err = context.Cause(msg.ctx); err != nil {
+			msg.finish(err)
+			continue
+		}
+		deadline, _ := msg.ctx.Deadline()
+		if err = writeMessage(ctx, conn, deadline, msg.data); err != nil {
+			msg.finish(err)
+			r.log().Warn("Write failed", "type", msg.event.Type, "err", err)
+			_ = conn.Close()
+			return
+		}
+		if msg.event.Type == events.RealtimeClientEventSessionUpdate {
+			update := *msg.event
+			r.lastSessionUpdate.Store(&update)
+		}
+		r.record(DirectionOut, msg.event, msg.data)
+		r.logEvent(ctx, "Event sent", msg.event)
+		msg.finish(nil)
+	}
+}
+
+// writeMessage writes data as a text message before deadline, a zero deadline
+// means no deadline. The write is interrupted when ctx is done.
+func writeMessage(ctx context.Context, conn *websocket.Conn, deadline time.Time, data []byte) error {
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.NetConn().SetWriteDeadline(time.Now())
+	})
+	defer stop()
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *realtimeClient) record(direction Direction, event *events.Event, raw []byte) {
+	if r.recorder == nil {
+		return
+	}
+	if err := r.recorder.Record(direction, event, raw); err != nil {
+		r.log().Warn("Record event failed", "type", event.Type, "err", err)
+	}
+}
+
+func (r *realtimeClient) baseLogger() *slog.Logger {
+	if r.logger != nil {
+		return r.logger
+	}
+	return slog.Default()
+}
+
+// log returns the logger of the client, with the session id once known.
+func (r *realtimeClient) log() *slog.Logger {
+	logger := r.baseLogger().With("component", "RealtimeClient")
+	if id := r.sessionID.Load(); id != nil {
+		logger = logger.With("session_id", *id)
+	}
+	return logger
+}
+
+// logEvent logs event at debug level, base64 payloads are truncated by
+// events.Event.LogValue.
+func (r *realtimeClient) logEvent(ctx context.Context, msg string, event *events.Event) {
+	if r.baseLogger().Enabled(ctx, slog.LevelDebug) {
+		r.log().DebugContext(ctx, msg, "event", event)
+	}
+}
+
+func responseStatus(rsp *http.Response) int {
+	if rsp == nil {
+		return 0
+	}
+	return rsp.StatusCode
+}
+
+// run reads messages for the whole session, reconnecting on transport errors
+// if a ReconnectPolicy is set. The events read are pushed to queue.
+func (r *realtimeClient) run(ctx context.Context, conn *websocket.Conn, queue *eventQueue) {
 	defer r.wg.Done()
-	deadline := time.Now().Add(waitTimeout)
-	for r.IsConnected() {
-		if time.Now().After(deadline) {
-			log.Printf("[RealtimeClient] ReadWsMsg loop time out after %v", waitTimeout)
+	defer queue.close()
+	r.lock.RLock()
+	out := r.outbox
+	r.lock.RUnlock()
+	for {
+		connCtx, stopPump := context.WithCancel(ctx)
+		go r.writePump(connCtx, conn, out)
+		go r.keepalive(connCtx, conn)
+		retry, err := r.readWsMsg(ctx, conn, queue)
+		stopPump()
+		if ctx.Err() != nil {
+			return
+		}
+		if !retry || r.reconnect == nil {
+			r.endSession(ctx, err)
+			return
+		}
+		// Close the old connection so that the server does not keep a second
+		// session when the read failed on a timeout.
+		_ = conn.Close()
+		if conn, err = r.redial(ctx, err); err != nil {
+			r.endSession(ctx, err)
 			return
 		}
+	}
+}
 
-		if conn := r.conn; conn != nil {
-			if err := conn.SetReadDeadline(time.Now().Add(15 * time.Second)); err != nil {
-				log.Printf("[RealtimeClient] SetReadDeadline failed: %v", err)
-			}
+// redial re-establishes the connection following the ReconnectPolicy and
+// replays the last session.update on the new connection.
+func (r *realtimeClient) redial(ctx context.Context, reason error) (*websocket.Conn, error) {
+	r.lock.Lock()
+	if r.state != StateConnected {
+		r.lock.Unlock()
+		return nil, context.Cause(ctx)
+	}
+	r.state = StateReconnecting
+	r.lock.Unlock()
+	r.notifyState(StateReconnecting, reason)
+
+	policy := *r.reconnect
+	for attempt := 0; policy.MaxAttempts < 0 || attempt < policy.MaxAttempts; attempt++ {
+		delay := policy.backoff(attempt)
+		r.log().Warn("Reconnecting", "delay", delay, "attempt", attempt+1, "reason", reason)
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-time.After(delay):
+		}
+
+		conn, err := r.dial(ctx)
+		if err != nil && !IsRetryable(err) {
+			return nil, fmt.Errorf("reconnect failed: %w", err)
 		}
-		messageType, message, err := r.conn.ReadMessage()
 		if err != nil {
-			log.Printf("[RealtimeClient] Read response failed, type: %d, message: %s, err: %v\n", messageType, string(message), err)
+			reason = err
+			continue
+		}
+		// The write pump of the new connection is not running yet, so the
+		// replay is the first message on it.
+		if update := r.lastSessionUpdate.Load(); update != nil {
+			data := []byte(update.ToJson())
+			if err = writeMessage(ctx, conn, time.Now().Add(replayWriteTimeout), data); err != nil {
+				_ = conn.Close()
+				reason = fmt.Errorf("replay session.update failed: %w", err)
+				continue
+			}
+			r.record(DirectionOut, update, data)
+		}
+		r.lock.Lock()
+		if r.state != StateReconnecting {
+			r.lock.Unlock()
+			_ = conn.Close()
+			return nil, context.Cause(ctx)
+		}
+		r.conn, r.state = conn, StateConnected
+		r.lock.Unlock()
+		r.notifyState(StateConnected, nil)
+		r.log().Info("Reconnected", "attempts", attempt+1)
+		return conn, nil
+	}
+	return nil, fmt.Errorf("reconnect failed after %d attempts: %w", policy.MaxAttempts, reason)
+}
+
+// handleEvents runs the handlers of the events read by run, in order, until
+// run has stopped and the queued events are handled. A handler error returned
+// by Dispatch ends the session.
+func (r *realtimeClient) handleEvents(ctx context.Context, queue *eventQueue) {
+	defer r.wg.Done()
+	for {
+		event, ok := queue.pop()
+		if !ok {
 			return
 		}
-		// log.Printf("[RealtimeClient] Received message type: %d, message len: %d\n", messageType, len(message))
-		if r.onReceived == nil {
-			log.Printf("[RealtimeClient] OnReceived is nil, skipping...\n")
-			continue
+		if err := r.Dispatch(event); err != nil {
+			r.endSession(ctx, err)
+			return
+		}
+	}
+}
+
+// readWsMsg reads messages from conn until it fails and pushes them to queue.
+// retry reports whether the failure is a transport error that a reconnect may
+// recover from.
+func (r *realtimeClient) readWsMsg(ctx context.Context, conn *websocket.Conn, queue *eventQueue) (retry bool, err error) {
+	for ctx.Err() == nil {
+		if err := r.extendReadDeadline(conn); err != nil {
+			r.log().Warn("SetReadDeadline failed", "err", err)
 		}
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			r.log().Warn("Read message failed", "message_type", messageType, "err", err)
+			err = transportError(err)
+			return IsRetryable(err), fmt.Errorf("read message failed: %w", err)
+		}
+		r.lastEventAt.Store(time.Now().UnixMilli())
 		event := &events.Event{}
 		if err = json.Unmarshal(message, event); err != nil {
-			log.Printf("[RealtimeClient] Unmarshal failed, err: %v\n", err)
-			_ = r.Disconnect()
-			return
+			r.log().Error("Unmarshal message failed", "message", events.TruncatePayload(string(message)), "err", err)
+			return false, fmt.Errorf("unmarshal message failed: %w", err)
 		}
-		if err = r.onReceived(event); err != nil {
-			log.Printf("[RealtimeClient] OnReceived failed, err: %v\n", err)
-			_ = r.Disconnect()
-			return
+		if event.Type == events.RealtimeServerEventSessionCreated && event.Session != nil && event.Session.ID != "" {
+			r.sessionID.Store(&event.Session.ID)
 		}
+		r.record(DirectionIn, event, message)
+		r.logEvent(ctx, "Event received", event)
+		r.limits.update(event)
+		// Acknowledgements are resolved here rather than by the handlers,
+		// which may be waiting for them.
+		r.pending.resolve(event)
+		queue.push(event)
 	}
+	return false, context.Cause(ctx)
 }
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestConnectContextDeadlineOnlyBoundsDial(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := c.ConnectContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	<-ctx.Done()
+	time.Sleep(100 * time.Millisecond)
+	if !c.IsConnected() {
+		t.Fatal("session ended with the deadline of the connect context")
+	}
+	if err := c.Send(&events.Event{Type: events.RealtimeClientEventInputAudioBufferClear}); err != nil {
+		t.Errorf("Send() after the connect deadline = %v", err)
+	}
+}
+
+func TestConnectContextCancelEndsSession(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	cause := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	if err := c.ConnectContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel(cause)
+
+	waitCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	if err := c.WaitContext(waitCtx); !errors.Is(err, cause) {
+		t.Errorf("WaitContext() = %v, want the cancel cause", err)
+	}
+	if c.State() != StateClosed {
+		t.Errorf("state %v after cancel", c.State())
+	}
+}
+
+func TestConnectContextCancelsDial(t *testing.T) {
+	// The listener accepts connections but never answers the handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	c := NewRealtimeClient("ws://"+ln.Addr().String(), "test", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.ConnectContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ConnectContext() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("dial took %v", elapsed)
+	}
+	if c.State() != StateClosed {
+		t.Errorf("state %v after a failed dial", c.State())
+	}
+}
+
+func TestSendContextDeadline(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if err := c.SendContext(ctx, &events.Event{Type: events.RealtimeClientEventInputAudioBufferClear}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SendContext() = %v, want context.DeadlineExceeded", err)
+	}
+	if err := c.Send(&events.Event{Type: events.RealtimeClientEventInputAudioBufferCommit}); err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	if _, err := srv.WaitFor(waitCtx, events.RealtimeClientEventInputAudioBufferCommit); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.ReceivedOfType(events.RealtimeClientEventInputAudioBufferClear); len(got) != 0 {
+		t.Errorf("expired event was sent: %v", got)
+	}
+}
+
+func TestWaitReturnsCause(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	srv.DropConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitContext(ctx); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitContext() = %v, want the read error", err)
+	}
+
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Disconnect()
+	if err := c.WaitContext(ctx); err != nil {
+		t.Errorf("WaitContext() after Disconnect = %v, want nil", err)
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// ConversationSnapshot is a point-in-time copy of a Conversation.
+type ConversationSnapshot struct {
+	ID    string        `json:"id"`
+	Items []events.Item `json:"items"`
+}
+
+// Conversation mirrors the server side conversation by applying the
+// conversation.* server events. It is safe for concurrent use.
+type Conversation struct {
+	lock  sync.RWMutex
+	id    string
+	items []events.Item
+}
+
+func NewConversation() *Conversation {
+	return &Conversation{}
+}
+
+// Attach registers the conversation on d and returns a function detaching it.
+func (c *Conversation) Attach(d *Dispatcher) (detach func()) {
+	return d.OnAny(c.Handle)
+}
+
+// Handle applies a server event, events not changing the conversation are ignored.
+func (c *Conversation) Handle(event *events.Event) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch event.Type {
+	case events.RealtimeServerEventConversationCreated:
+		c.id, c.items = "", nil
+		if event.Conversation != nil {
+			c.id = event.Conversation.ID
+		}
+	case events.RealtimeServerEventConversationItemCreated:
+		if event.Item != nil {
+			c.insert(event.PreviousItemID, *event.Item)
+		}
+	case events.RealtimeServerEventConversationItemRetrieved, events.RealtimeServerEventResponseOutputItemDone:
+		if event.Item == nil {
+			break
+		}
+		if i := c.indexOf(event.Item.ID); i >= 0 {
+			c.items[i] = *event.Item
+		} else if event.Type == events.RealtimeServerEventConversationItemRetrieved {
+			c.items = append(c.items, *event.Item)
+		}
+	case events.RealtimeServerEventConversationItemTruncated:
+		// The server drops the transcript of the truncated audio.
+		if i := c.indexOf(event.ItemID); i >= 0 && event.ContentIndex < len(c.items[i].Content) {
+			content := append([]events.Content(nil), c.items[i].Content...)
+			content[event.ContentIndex].Transcript = nil
+			c.items[i].Content = content
+		}
+	case events.RealtimeServerEventConversationItemInputAudioTranscriptionCompleted:
+		if i := c.indexOf(event.ItemID); i >= 0 && event.ContentIndex < len(c.items[i].Content) && event.Transcript != nil {
+			content := append([]events.Content(nil), c.items[i].Content...)
+			transcript := *event.Transcript
+			content[event.ContentIndex].Transcript = &transcript
+			c.items[i].Content = content
+		}
+	case events.RealtimeServerEventConversationItemDeleted:
+		if i := c.indexOf(event.ItemID); i >= 0 {
+			c.items = append(c.items[:i:i], c.items[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// insert places item after the item with id previousID. An empty previousID
+// means the item has no predecessor, so it goes first. An unknown previousID
+// means the predecessor was not mirrored, e.g. because it was created before
+// the conversation was attached; the item then goes last, where the server
+// adds new items. An item already present is replaced in place.
+func (c *Conversation) insert(previousID string, item events.Item) {
+	if i := c.indexOf(item.ID); i >= 0 {
+		c.items[i] = item
+		return
+	}
+	at := 0
+	if previousID != "" {
+		at = len(c.items)
+		if i := c.indexOf(previousID); i >= 0 {
+			at = i + 1
+		}
+	}
+	c.items = append(c.items, events.Item{})
+	copy(c.items[at+1:], c.items[at:])
+	c.items[at] = item
+}
+
+func (c *Conversation) indexOf(id string) int {
+	for i := range c.items {
+		if c.items[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ID returns the id of the conversation.
+func (c *Conversation) ID() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.id
+}
+
+// Len returns the number of items.
+func (c *Conversation) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.items)
+}
+
+// Item returns the item with the given id.
+func (c *Conversation) Item(id string) (events.Item, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if i := c.indexOf(id); i >= 0 {
+		return c.items[i], true
+	}
+	return events.Item{}, false
+}
+
+// Range calls fn for each item in order until fn returns false. The
+// conversation is locked for reading meanwhile, so fn must not modify it.
+func (c *Conversation) Range(fn func(index int, item events.Item) bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for i, item := range c.items {
+		if !fn(i, item) {
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of the conversation.
+func (c *Conversation) Snapshot() ConversationSnapshot {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return ConversationSnapshot{ID: c.id, Items: append([]events.Item(nil), c.items...)}
+}
+
+// Export writes the snapshot of the conversation to w as JSON.
+func (c *Conversation) Export(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c.Snapshot())
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestConversation(t *testing.T) {
+	conv := NewConversation()
+	created := func(previousID, id string) *events.Event {
+		return &events.Event{
+			Type:           events.RealtimeServerEventConversationItemCreated,
+			PreviousItemID: previousID,
+			Item:           &events.Item{ID: id, Type: events.ItemTypeMessage},
+		}
+	}
+	for _, event := range []*events.Event{
+		{Type: events.RealtimeServerEventConversationCreated, Conversation: &events.Conversation{ID: "conv_1"}},
+		created("", "a"),
+		created("a", "c"),
+		created("a", "b"),
+		created("c", "d"),
+		{Type: events.RealtimeServerEventConversationItemDeleted, ItemID: "c"},
+		// No predecessor: first. Unknown predecessor: last.
+		created("", "first"),
+		created("item_unknown", "z"),
+	} {
+		if err := conv.Handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := conv.Snapshot()
+	var ids []string
+	for _, item := range snapshot.Items {
+		ids = append(ids, item.ID)
+	}
+	if want := []string{"first", "a", "b", "d", "z"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("items = %v, want %v", ids, want)
+	}
+	if snapshot.ID != "conv_1" {
+		t.Errorf("id = %q", snapshot.ID)
+	}
+	if _, ok := conv.Item("c"); ok {
+		t.Error("deleted item still present")
+	}
+}
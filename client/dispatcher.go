@@ -0,0 +1,226 @@
+package client
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// Handler handles a server event.
+type Handler func(event *events.Event) error
+
+type handlerEntry struct {
+	id      uint64
+	handler Handler
+}
+
+// Dispatcher routes server events to handlers registered per event type.
+// For every event the handlers of its type run first, then the catch-all
+// handlers, each group in registration order. A handler error is logged and
+// the remaining handlers still run; Dispatch only returns it when StopOnError
+// is set, which makes the client close the connection.
+//
+// The client runs the handlers on a goroutine of their own, fed in order by
+// the read loop, so a slow handler delays later handlers but not the reading
+// of the connection, and may wait for server events with SendAndAwait. The
+// events read meanwhile are queued without bound.
+type Dispatcher struct {
+	StopOnError bool
+	// Logger logs handler errors, slog.Default() if nil.
+	Logger *slog.Logger
+
+	lock     sync.RWMutex
+	nextID   uint64
+	handlers map[events.EventType][]handlerEntry
+	catchAll []handlerEntry
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{handlers: make(map[events.EventType][]handlerEntry)}
+}
+
+// On registers h for events of type t and returns a function that removes it.
+func (d *Dispatcher) On(t events.EventType, h Handler) (remove func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.nextID++
+	id := d.nextID
+	d.handlers[t] = append(d.handlers[t], handlerEntry{id: id, handler: h})
+	return func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		d.handlers[t] = removeEntry(d.handlers[t], id)
+	}
+}
+
+// OnAny registers a catch-all handler h and returns a function that removes it.
+func (d *Dispatcher) OnAny(h Handler) (remove func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.nextID++
+	id := d.nextID
+	d.catchAll = append(d.catchAll, handlerEntry{id: id, handler: h})
+	return func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		d.catchAll = removeEntry(d.catchAll, id)
+	}
+}
+
+func removeEntry(entries []handlerEntry, id uint64) []handlerEntry {
+	for i := range entries {
+		if entries[i].id == id {
+			// Copy so that a Dispatch iterating the old slice is not affected.
+			return append(entries[:i:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+func (d *Dispatcher) OnError(h Handler) func() {
+	return d.On(events.RealtimeServerEventError, h)
+}
+
+// OnServerError registers h for error events, delivered as *ServerError.
+func (d *Dispatcher) OnServerError(h func(err *ServerError) error) func() {
+	return d.OnError(func(event *events.Event) error {
+		return h(newServerError(event))
+	})
+}
+
+func (d *Dispatcher) OnSessionCreated(h Handler) func() {
+	return d.On(events.RealtimeServerEventSessionCreated, h)
+}
+
+func (d *Dispatcher) OnSessionUpdated(h Handler) func() {
+	return d.On(events.RealtimeServerEventSessionUpdated, h)
+}
+
+func (d *Dispatcher) OnSpeechStarted(h Handler) func() {
+	return d.On(events.RealtimeServerEventInputAudioBufferSpeechStarted, h)
+}
+
+func (d *Dispatcher) OnSpeechStopped(h Handler) func() {
+	return d.On(events.RealtimeServerEventInputAudioBufferSpeechStopped, h)
+}
+
+func (d *Dispatcher) OnResponseCreated(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseCreated, h)
+}
+
+func (d *Dispatcher) OnResponseDone(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseDone, h)
+}
+
+func (d *Dispatcher) OnTextDelta(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseTextDelta, h)
+}
+
+func (d *Dispatcher) OnTextDone(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseTextDone, h)
+}
+
+func (d *Dispatcher) OnAudioDelta(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseAudioDelta, h)
+}
+
+func (d *Dispatcher) OnAudioDone(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseAudioDone, h)
+}
+
+func (d *Dispatcher) OnAudioTranscriptDelta(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseAudioTranscriptDelta, h)
+}
+
+func (d *Dispatcher) OnFunctionCallArgumentsDone(h Handler) func() {
+	return d.On(events.RealtimeServerEventResponseFunctionCallArgumentsDone, h)
+}
+
+func (d *Dispatcher) OnRateLimitsUpdated(h Handler) func() {
+	return d.On(events.RealtimeServerEventRateLimitsUpdated, h)
+}
+
+// Dispatch runs the handlers registered for event.
+func (d *Dispatcher) Dispatch(event *events.Event) error {
+	d.lock.RLock()
+	typed, catchAll := d.handlers[event.Type], d.catchAll
+	d.lock.RUnlock()
+
+	for _, group := range [][]handlerEntry{typed, catchAll} {
+		for _, entry := range group {
+			if err := entry.handler(event); err != nil {
+				d.logger().Warn("Handler failed", "type", event.Type, "event_id", event.EventID, "err", err)
+				if d.StopOnError {
+					return fmt.Errorf("handle %s failed: %w", event.Type, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// eventQueue is the unbounded queue of server events between the read loop
+// and the goroutine running the handlers. It never blocks the read loop, which
+// must keep reading the acknowledgements a handler may be waiting for.
+type eventQueue struct {
+	lock   sync.Mutex
+	events []*events.Event
+	ready  chan struct{} // signalled when an event is pushed or the queue closed
+	closed bool
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *eventQueue) push(event *events.Event) {
+	q.lock.Lock()
+	q.events = append(q.events, event)
+	q.lock.Unlock()
+	q.signal()
+}
+
+// close makes pop fail once the queued events are taken.
+func (q *eventQueue) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.lock.Unlock()
+	q.signal()
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop blocks until an event is queued, ok is false if the queue is closed
+// and empty.
+func (q *eventQueue) pop() (event *events.Event, ok bool) {
+	for {
+		q.lock.Lock()
+		if len(q.events) > 0 {
+			event = q.events[0]
+			q.events[0] = nil
+			q.events = q.events[1:]
+			q.lock.Unlock()
+			return event, true
+		}
+		closed := q.closed
+		q.lock.Unlock()
+		if closed {
+			return nil, false
+		}
+		<-q.ready
+	}
+}
+
+func (d *Dispatcher) logger() *slog.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return slog.Default()
+}
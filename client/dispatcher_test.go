@@ -0,0 +1,47 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestDispatcherOrder(t *testing.T) {
+	d := NewDispatcher()
+	var calls []string
+	d.OnAny(func(event *events.Event) error {
+		calls = append(calls, "any")
+		return nil
+	})
+	d.OnTextDelta(func(event *events.Event) error {
+		calls = append(calls, "text1")
+		return errors.New("boom")
+	})
+	remove := d.OnTextDelta(func(event *events.Event) error {
+		calls = append(calls, "text2")
+		return nil
+	})
+	d.OnAudioDelta(func(event *events.Event) error {
+		calls = append(calls, "audio")
+		return nil
+	})
+
+	if err := d.Dispatch(&events.Event{Type: events.RealtimeServerEventResponseTextDelta}); err != nil {
+		t.Fatalf("Dispatch returned %v without StopOnError", err)
+	}
+	if want := []string{"text1", "text2", "any"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	remove()
+	d.StopOnError = true
+	if err := d.Dispatch(&events.Event{Type: events.RealtimeServerEventResponseTextDelta}); err == nil {
+		t.Fatal("Dispatch returned nil with StopOnError")
+	}
+	if want := []string{"text1"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
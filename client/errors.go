@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// Errors returned by the client. ServerError and HandshakeError also match
+// ErrRateLimited, ErrInvalidRequest and ErrAuthFailed with errors.Is according
+// to their type, code or status.
+var (
+	ErrNotConnected   = errors.New("not connected")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrAuthFailed     = errors.New("authentication failed")
+	// ErrMissingParameterSets is returned by SendFrameByVideo for H.264 data
+	// arriving before the SPS and PPS of the stream.
+	ErrMissingParameterSets = errors.New("H.264 SPS/PPS not known yet")
+)
+
+// IsRetryable reports whether the operation that failed with err may succeed
+// when tried again, e.g. after a reconnect or a backoff. Errors not known to
+// be transient are not retryable.
+func IsRetryable(err error) bool {
+	// context.DeadlineExceeded is also a net.Error, but the caller's own
+	// deadline or cancellation is not a transient failure.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// ServerError is the error of a server error event.
+type ServerError struct {
+	*events.EventError
+}
+
+func newServerError(event *events.Event) *ServerError {
+	if event.Error == nil {
+		return &ServerError{EventError: &events.EventError{Type: "server_error"}}
+	}
+	return &ServerError{EventError: event.Error}
+}
+
+func (e *ServerError) Error() string {
+	if e.Param != "" {
+		return fmt.Sprintf("server error, type: %s, code: %s, param: %s, message: %s", e.Type, e.Code, e.Param, e.Message)
+	}
+	return fmt.Sprintf("server error, type: %s, code: %s, message: %s", e.Type, e.Code, e.Message)
+}
+
+func (e *ServerError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.Type == "rate_limit_error" || strings.Contains(e.Code, "rate_limit")
+	case ErrAuthFailed:
+		return e.Type == "authentication_error" || e.Code == "invalid_api_key"
+	case ErrInvalidRequest:
+		return e.Type == "invalid_request_error"
+	}
+	return false
+}
+
+// Retryable reports whether the request may succeed when sent again: rate
+// limits and internal server errors are transient, anything else is not.
+func (e *ServerError) Retryable() bool {
+	return e.Is(ErrRateLimited) || e.Type == "server_error"
+}
+
+// CloseError is returned when the server closes the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("connection closed by server, code: %d, reason: %s", e.Code, e.Reason)
+}
+
+// Retryable reports whether a reconnect may help. A normal closure and the
+// codes rejecting what the client sent are final.
+func (e *CloseError) Retryable() bool {
+	switch e.Code {
+	case websocket.CloseNormalClosure, websocket.CloseProtocolError, websocket.CloseUnsupportedData,
+		websocket.CloseInvalidFramePayloadData, websocket.ClosePolicyViolation, websocket.CloseMessageTooBig:
+		return false
+	}
+	return true
+}
+
+// HandshakeError is returned when the server rejects the WebSocket handshake.
+type HandshakeError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake failed, status: %d, err: %v", e.StatusCode, e.Err)
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HandshakeError) Is(target error) bool {
+	switch target {
+	case ErrAuthFailed:
+		return e.StatusCode == 401 || e.StatusCode == 403
+	case ErrRateLimited:
+		return e.StatusCode == 429
+	case ErrInvalidRequest:
+		return e.StatusCode == 400
+	}
+	return false
+}
+
+func (e *HandshakeError) Retryable() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
+
+// transportError converts the close errors of the websocket package to CloseError.
+func transportError(err error) error {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return &CloseError{Code: closeErr.Code, Reason: closeErr.Text}
+	}
+	return err
+}
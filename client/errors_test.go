@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestServerErrorClassification(t *testing.T) {
+	tests := []struct {
+		err       *events.EventError
+		target    error
+		retryable bool
+	}{
+		{&events.EventError{Type: "invalid_request_error", Code: "invalid_value", Param: "session.voice"}, ErrInvalidRequest, false},
+		{&events.EventError{Type: "rate_limit_error", Code: "rate_limit_exceeded"}, ErrRateLimited, true},
+		{&events.EventError{Type: "authentication_error", Code: "invalid_api_key"}, ErrAuthFailed, false},
+		{&events.EventError{Type: "server_error", Code: "internal_error"}, nil, true},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("send failed: %w", newServerError(&events.Event{Error: tt.err}))
+		var serverErr *ServerError
+		if !errors.As(err, &serverErr) || serverErr.Code != tt.err.Code {
+			t.Errorf("errors.As(%v) did not find the ServerError", err)
+		}
+		if tt.target != nil && !errors.Is(err, tt.target) {
+			t.Errorf("errors.Is(%v, %v) = false", err, tt.target)
+		}
+		if got := IsRetryable(err); got != tt.retryable {
+			t.Errorf("IsRetryable(%v) = %v, want %v", err, got, tt.retryable)
+		}
+	}
+	if msg := newServerError(&events.Event{Error: tests[0].err}).Error(); !strings.Contains(msg, "session.voice") {
+		t.Errorf("Error() = %q, want the param", msg)
+	}
+}
+
+func TestCloseErrorRetryable(t *testing.T) {
+	normal := transportError(&websocket.CloseError{Code: websocket.CloseNormalClosure})
+	if IsRetryable(normal) {
+		t.Errorf("normal closure is retryable")
+	}
+	abnormal := fmt.Errorf("read message failed: %w", transportError(&websocket.CloseError{Code: websocket.CloseAbnormalClosure}))
+	var closeErr *CloseError
+	if !errors.As(abnormal, &closeErr) || closeErr.Code != websocket.CloseAbnormalClosure || !IsRetryable(abnormal) {
+		t.Errorf("abnormal closure: %v, want a retryable CloseError", abnormal)
+	}
+}
+
+func TestConnectAuthFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := NewRealtimeClient("ws"+strings.TrimPrefix(srv.URL, "http"), "wrong", nil)
+	err := c.Connect()
+	var handshakeErr *HandshakeError
+	if !errors.Is(err, ErrAuthFailed) || !errors.As(err, &handshakeErr) || handshakeErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Connect error = %v, want HandshakeError 401", err)
+	}
+	if IsRetryable(err) {
+		t.Errorf("auth failure is retryable")
+	}
+	if err := c.Send(&events.Event{Type: events.RealtimeClientEventResponseCreate}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Send error = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestContextErrorsNotRetryable(t *testing.T) {
+	for _, err := range []error{
+		context.DeadlineExceeded,
+		context.Canceled,
+		fmt.Errorf("send failed: %w", context.DeadlineExceeded),
+	} {
+		if IsRetryable(err) {
+			t.Errorf("IsRetryable(%v) = true", err)
+		}
+	}
+	timeout := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	if !IsRetryable(timeout) {
+		t.Errorf("IsRetryable(%v) = false, want a read timeout retryable", timeout)
+	}
+}
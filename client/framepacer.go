@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// DefaultFPS is the video frame rate used when the session sets no
+// BetaFields.FPS.
+const DefaultFPS = 2
+
+// FramePacerStats counts the frames of a FramePacer.
+type FramePacerStats struct {
+	Sent int64
+	// Dropped frames were replaced by a fresher one before being sent.
+	Dropped int64
+	// Duplicated frames were sent again because no new frame arrived in time.
+	Duplicated int64
+}
+
+// FramePacer sends video frames pushed by a producer as
+// input_audio_buffer.append_video_frame events at a fixed rate. Only the
+// freshest frame is kept when the producer is faster; when it is slower the
+// last frame is repeated if Repeat is set. The rate follows BetaFields.FPS of
+// the session once attached to the client's Dispatcher.
+type FramePacer struct {
+	// Repeat sends the last frame again when no new frame arrived.
+	Repeat bool
+
+	client RealtimeClient
+	fps    atomic.Int64
+
+	lock   sync.Mutex
+	latest []byte
+	fresh  bool // latest was not sent yet
+
+	sent, dropped, duplicated atomic.Int64
+}
+
+// NewFramePacer returns a pacer sending fps frames per second, DefaultFPS if
+// fps is not positive.
+func NewFramePacer(c RealtimeClient, fps int) *FramePacer {
+	p := &FramePacer{Repeat: true, client: c}
+	p.SetFPS(fps)
+	return p
+}
+
+// SetFPS changes the frame rate, DefaultFPS if fps is not positive.
+func (p *FramePacer) SetFPS(fps int) {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	p.fps.Store(int64(fps))
+}
+
+// FPS returns the current frame rate.
+func (p *FramePacer) FPS() int {
+	return int(p.fps.Load())
+}
+
+// Attach makes the pacer follow BetaFields.FPS of session.created and
+// session.updated and returns a function detaching it.
+func (p *FramePacer) Attach(d *Dispatcher) (detach func()) {
+	handle := func(event *events.Event) error {
+		if event.Session != nil && event.Session.BetaFields != nil {
+			p.SetFPS(event.Session.BetaFields.FPS)
+		}
+		return nil
+	}
+	removeCreated := d.OnSessionCreated(handle)
+	removeUpdated := d.OnSessionUpdated(handle)
+	return func() {
+		removeCreated()
+		removeUpdated()
+	}
+}
+
+// Push hands a new frame to the pacer, replacing the frame waiting to be sent.
+func (p *FramePacer) Push(frame []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.fresh {
+		p.dropped.Add(1)
+	}
+	p.latest, p.fresh = frame, true
+}
+
+// Stats returns the frame counts so far.
+func (p *FramePacer) Stats() FramePacerStats {
+	return FramePacerStats{Sent: p.sent.Load(), Dropped: p.dropped.Load(), Duplicated: p.duplicated.Load()}
+}
+
+// Run sends frames until ctx is done or a send fails. Frames dropped by the
+// outbound queue are counted as dropped and do not stop the pacer.
+func (p *FramePacer) Run(ctx context.Context) error {
+	fps := p.FPS()
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-ticker.C:
+		}
+		if current := p.FPS(); current != fps {
+			fps = current
+			ticker.Reset(time.Second / time.Duration(fps))
+		}
+
+		p.lock.Lock()
+		frame, fresh := p.latest, p.fresh
+		p.fresh = false
+		p.lock.Unlock()
+		if frame == nil || !fresh && !p.Repeat {
+			continue
+		}
+		err := p.client.SendContext(ctx, &events.Event{Type: events.RealtimeClientVideoAppend, VideoFrame: frame})
+		switch {
+		case errors.Is(err, ErrFrameDropped):
+			p.dropped.Add(1)
+		case err != nil:
+			return err
+		case fresh:
+			p.sent.Add(1)
+		default:
+			p.sent.Add(1)
+			p.duplicated.Add(1)
+		}
+	}
+}
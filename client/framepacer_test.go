@@ -0,0 +1,58 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestFramePacer(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	pacer := NewFramePacer(c, 0)
+	pacer.Attach(c.Dispatcher)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fps := 20
+	if _, err := c.UpdateSession(ctx, &events.Session{BetaFields: &events.BetaFields{FPS: fps}}); err != nil {
+		t.Fatal(err)
+	}
+	// The session.updated handler may run after UpdateSession returns.
+	for pacer.FPS() != fps && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if pacer.FPS() != fps {
+		t.Fatalf("FPS() = %d, want %d from session.updated", pacer.FPS(), fps)
+	}
+
+	for _, frame := range []string{"frame1", "frame2", "frame3"} {
+		pacer.Push([]byte(frame))
+	}
+	runCtx, stop := context.WithTimeout(ctx, 4*time.Second/time.Duration(fps)+time.Second/time.Duration(2*fps))
+	defer stop()
+	if err := pacer.Run(runCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	stats := pacer.Stats()
+	// About 4 ticks happen, only the first sends a new frame.
+	if stats.Dropped != 2 || stats.Sent < 2 || stats.Duplicated != stats.Sent-1 {
+		t.Errorf("stats = %+v, want 2 dropped and all but the first sent frame duplicated", stats)
+	}
+	for _, event := range srv.ReceivedOfType(events.RealtimeClientVideoAppend) {
+		if !bytes.Equal(event.VideoFrame, []byte("frame3")) {
+			t.Errorf("sent %q, want only the freshest frame", event.VideoFrame)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
+)
+
+// parameterSets caches the last H.264 SPS and PPS seen in the video sent by
+// SendFrameByVideo during a session. The override set by
+// WithH264ParameterSets takes precedence.
+type parameterSets struct {
+	overrideSPS, overridePPS string
+
+	lock     sync.Mutex
+	sps, pps []byte
+}
+
+func (p *parameterSets) reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sps, p.pps = nil, nil
+}
+
+// observe records the parameter sets in data and returns the ones to use
+// for it, base64 encoded.
+func (p *parameterSets) observe(data []byte) (sps, pps string, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	first := 0 // type of the first slice
+	for _, nal := range tools.SplitAnnexB(data) {
+		switch t := tools.NALType(nal); t {
+		case tools.NALTypeSPS:
+			p.sps = append([]byte(nil), nal...)
+		case tools.NALTypePPS:
+			p.pps = append([]byte(nil), nal...)
+		case tools.NALTypeNonIDR, tools.NALTypeIDR:
+			if first == 0 {
+				first = t
+			}
+		}
+	}
+	if p.overrideSPS != "" && p.overridePPS != "" {
+		return p.overrideSPS, p.overridePPS, nil
+	}
+	if p.sps == nil || p.pps == nil {
+		if first == tools.NALTypeNonIDR {
+			return "", "", fmt.Errorf("delta frame before any keyframe: %w", ErrMissingParameterSets)
+		}
+		return "", "", ErrMissingParameterSets
+	}
+	return base64.StdEncoding.EncodeToString(p.sps), base64.StdEncoding.EncodeToString(p.pps), nil
+}
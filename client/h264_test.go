@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
+)
+
+func annexB(nals ...[]byte) []byte {
+	var data []byte
+	for _, nal := range nals {
+		data = append(data, 0, 0, 0, 1)
+		data = append(data, nal...)
+	}
+	return data
+}
+
+func TestParameterSetsFromStream(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0xc0, 0x0c, 0x9a}
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+	idr := []byte{0x65, 0x88, 0x84}
+	delta := []byte{0x41, 0x9a, 0x02}
+	var p parameterSets
+	if _, _, err := p.observe(annexB(delta)); !errors.Is(err, ErrMissingParameterSets) {
+		t.Fatalf("delta frame first: err = %v, want ErrMissingParameterSets", err)
+	}
+	for _, data := range [][]byte{annexB(sps, pps, idr), annexB(delta)} {
+		gotSPS, gotPPS, err := p.observe(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotSPS != base64.StdEncoding.EncodeToString(sps) || gotPPS != base64.StdEncoding.EncodeToString(pps) {
+			t.Errorf("parameter sets = %s, %s, want the ones of the stream", gotSPS, gotPPS)
+		}
+	}
+
+	p.reset()
+	p.overrideSPS, p.overridePPS = "Z0LADJoFAAABMA==", "aM48gA=="
+	if gotSPS, _, err := p.observe(annexB(delta)); err != nil || gotSPS != p.overrideSPS {
+		t.Errorf("with override: %s, %v", gotSPS, err)
+	}
+}
+
+func TestSendFrameByVideoWithExtractor(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	extractor := &tools.FakeExtractor{}
+	c := NewRealtimeClient(srv.URL, "test", nil, WithFrameExtractor(extractor))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	sps := []byte{0x67, 0x42, 0xc0, 0x0c, 0x9a}
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	video := annexB(sps, pps, []byte{0x65, 0x88, 0x84}, []byte{0x41, 0x9a, 0x02})
+	if err := c.SendFrameByVideoContext(ctx, &events.Event{Type: events.RealtimeClientVideoAppend, VideoFrame: video}); err != nil {
+		t.Fatal(err)
+	}
+	calls := extractor.Calls()
+	if len(calls) != 1 || !bytes.HasPrefix(calls[0], annexB(sps, pps)) {
+		t.Fatalf("extractor calls %x, want the video with its parameter sets first", calls)
+	}
+	// The fake extracts a frame per access unit: the IDR and the delta frame.
+	var frames []*events.Event
+	for len(frames) < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("received %d frames, want 2", len(frames))
+		case <-time.After(10 * time.Millisecond):
+		}
+		frames = srv.ReceivedOfType(events.RealtimeClientVideoAppend)
+	}
+	if string(frames[0].VideoFrame) != "frame 0" || string(frames[1].VideoFrame) != "frame 1" {
+		t.Errorf("sent frames %q, %q", frames[0].VideoFrame, frames[1].VideoFrame)
+	}
+
+	extractor.Err = errors.New("boom")
+	if err := c.SendFrameByVideo(&events.Event{Type: events.RealtimeClientVideoAppend, VideoFrame: video}); !errors.Is(err, extractor.Err) {
+		t.Errorf("SendFrameByVideo() = %v, want the extractor error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval = 15 * time.Second
+	defaultPongTimeout  = 15 * time.Second
+	minMonitorInterval  = 100 * time.Millisecond
+)
+
+// readTimeout is how long a read may block before the connection is
+// considered dead, zero when keepalive is disabled.
+func (r *realtimeClient) readTimeout() time.Duration {
+	if r.pingInterval <= 0 {
+		return 0
+	}
+	return r.pingInterval + r.pongTimeout
+}
+
+func (r *realtimeClient) extendReadDeadline(conn *websocket.Conn) error {
+	var deadline time.Time
+	if timeout := r.readTimeout(); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	return conn.SetReadDeadline(deadline)
+}
+
+// keepalive pings conn every pingInterval until ctx is done. A missing pong
+// makes the pending read time out, which is handled like any transport error.
+func (r *realtimeClient) keepalive(ctx context.Context, conn *websocket.Conn) {
+	if r.pingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(r.pongTimeout)); err != nil {
+				r.log().Warn("Ping failed", "err", err)
+				return
+			}
+		}
+	}
+}
+
+// monitor watches the time since the last server event, ending the session
+// after idleTimeout and calling onStall once per stall longer than stallAfter.
+func (r *realtimeClient) monitor(ctx context.Context) {
+	interval := r.idleTimeout
+	if interval <= 0 || r.stallAfter > 0 && r.stallAfter < interval {
+		interval = r.stallAfter
+	}
+	if interval <= 0 {
+		return
+	}
+	if interval /= 4; interval < minMonitorInterval {
+		interval = minMonitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// stalledAt is lastEventAt of the stall reported last, a new stall needs
+	// a server event in between.
+	var stalledAt int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		last := r.lastEventAt.Load()
+		idle := time.Since(time.UnixMilli(last))
+		if r.idleTimeout > 0 && idle >= r.idleTimeout {
+			r.endSession(ctx, fmt.Errorf("no server event for %v", idle.Truncate(time.Millisecond)))
+			return
+		}
+		if r.stallAfter > 0 && idle >= r.stallAfter && last != stalledAt {
+			stalledAt = last
+			r.log().Warn("Server stalled", "idle", idle.Truncate(time.Millisecond))
+			if r.onStall != nil {
+				r.onStall(idle)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// waitSession connects c to srv and returns the reason the session ended.
+func waitSession(t *testing.T, c *realtimeClient) error {
+	t.Helper()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := c.WaitContext(ctx)
+	if ctx.Err() != nil {
+		t.Fatal("session did not end")
+	}
+	return err
+}
+
+func TestIdleTimeout(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	// Pongs keep the connection alive but are not server events.
+	c := NewRealtimeClient(srv.URL, "test", nil, WithKeepalive(20*time.Millisecond, time.Second), WithIdleTimeout(200*time.Millisecond))
+	start := time.Now()
+	err := waitSession(t, c)
+	if err == nil || !strings.Contains(err.Error(), "no server event") {
+		t.Errorf("Wait() = %v, want the idle timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("session ended after %v", elapsed)
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil, WithSessionTimeout(150*time.Millisecond))
+	if err := waitSession(t, c); err == nil || !strings.Contains(err.Error(), "session timed out") {
+		t.Errorf("Wait() = %v, want the session timeout", err)
+	}
+}
+
+func TestKeepaliveKeepsQuietConnection(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil, WithKeepalive(30*time.Millisecond, 30*time.Millisecond))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	// Without pongs the read deadline of 60ms would expire.
+	time.Sleep(300 * time.Millisecond)
+	if !c.IsConnected() {
+		t.Error("quiet connection dropped despite pongs")
+	}
+}
+
+func TestStallHandler(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	var stalls atomic.Int32
+	c := NewRealtimeClient(srv.URL, "test", nil, WithStallHandler(100*time.Millisecond, func(idle time.Duration) {
+		if idle < 100*time.Millisecond {
+			t.Errorf("stall reported after %v", idle)
+		}
+		stalls.Add(1)
+	}))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	time.Sleep(400 * time.Millisecond)
+	if n := stalls.Load(); n != 1 {
+		t.Fatalf("%d stalls reported, want 1 for one long stall", n)
+	}
+	// A server event ends the stall, the next one is reported again.
+	if err := srv.Send(&events.Event{Type: events.RealtimeServerEventInputAudioBufferCleared}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if n := stalls.Load(); n != 2 {
+		t.Errorf("%d stalls reported, want 2", n)
+	}
+	if !c.IsConnected() {
+		t.Error("stall ended the session")
+	}
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/tools"
+)
+
+// Option configures a realtimeClient created by NewRealtimeClient.
+type Option func(r *realtimeClient)
+
+// WithDialer dials with a copy of d instead of websocket.DefaultDialer. The
+// dialer options are applied to the copy whatever their order.
+func WithDialer(d *websocket.Dialer) Option {
+	return func(r *realtimeClient) {
+		dialer := *d
+		r.dialer = &dialer
+	}
+}
+
+// adjustDialer records a change of the dialer applied by NewRealtimeClient
+// after all options, so that WithDialer does not discard it.
+func (r *realtimeClient) adjustDialer(fn func(d *websocket.Dialer)) {
+	r.dialerOpts = append(r.dialerOpts, fn)
+}
+
+// WithHeader adds a header sent with the handshake request.
+func WithHeader(key, value string) Option {
+	return func(r *realtimeClient) {
+		r.header.Add(key, value)
+	}
+}
+
+// WithTLSConfig sets the TLS config used for wss:// URLs, for example to
+// trust a private CA.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(r *realtimeClient) {
+		r.adjustDialer(func(d *websocket.Dialer) {
+			d.TLSClientConfig = config
+		})
+	}
+}
+
+// WithProxy dials through the HTTP proxy at proxyURL. Without this option
+// the proxy is taken from the environment.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(r *realtimeClient) {
+		r.adjustDialer(func(d *websocket.Dialer) {
+			d.Proxy = http.ProxyURL(proxyURL)
+		})
+	}
+}
+
+// WithHandshakeTimeout bounds the WebSocket handshake.
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(r *realtimeClient) {
+		r.adjustDialer(func(dialer *websocket.Dialer) {
+			dialer.HandshakeTimeout = d
+		})
+	}
+}
+
+// WithBufferSizes sets the read and write buffer sizes of the connection.
+func WithBufferSizes(readSize, writeSize int) Option {
+	return func(r *realtimeClient) {
+		r.adjustDialer(func(d *websocket.Dialer) {
+			d.ReadBufferSize, d.WriteBufferSize = readSize, writeSize
+		})
+	}
+}
+
+// WithCompression negotiates permessage-deflate compression with the server.
+func WithCompression(enable bool) Option {
+	return func(r *realtimeClient) {
+		r.adjustDialer(func(d *websocket.Dialer) {
+			d.EnableCompression = enable
+		})
+	}
+}
+
+// WithMaxMessageSize limits the size of a message read from the server.
+func WithMaxMessageSize(n int64) Option {
+	return func(r *realtimeClient) {
+		r.maxMessageSize = n
+	}
+}
+
+// WithReconnect enables automatic reconnect after transport errors. After a
+// successful reconnect the last session.update sent is replayed.
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(r *realtimeClient) {
+		p := policy.withDefaults()
+		r.reconnect = &p
+	}
+}
+
+// WithStateHandler registers fn to be called on every connection state change.
+// err is the reason of the change for StateReconnecting and StateClosed.
+func WithStateHandler(fn func(state ConnState, err error)) Option {
+	return func(r *realtimeClient) {
+		r.onStateChange = fn
+	}
+}
+
+// WithStopOnHandlerError makes a handler error close the connection.
+func WithStopOnHandlerError() Option {
+	return func(r *realtimeClient) {
+		r.StopOnError = true
+	}
+}
+
+// WithOutboundQueue sets the size of the outbound queue and what Send does
+// when it is full. The default is a queue of 256 events with QueueFullBlock.
+func WithOutboundQueue(size int, policy QueueFullPolicy) Option {
+	return func(r *realtimeClient) {
+		r.queueSize, r.queuePolicy = size, policy
+	}
+}
+
+// WithPriority sets the priority of events of type t in the outbound queue.
+func WithPriority(t events.EventType, p Priority) Option {
+	return func(r *realtimeClient) {
+		r.priorities[t] = p
+	}
+}
+
+// WithKeepalive pings the server every interval and drops the connection if
+// nothing, pong included, is received within interval+timeout. A non-positive
+// interval disables keepalive. The default is 15s/15s.
+func WithKeepalive(interval, timeout time.Duration) Option {
+	return func(r *realtimeClient) {
+		r.pingInterval, r.pongTimeout = interval, timeout
+	}
+}
+
+// WithIdleTimeout ends the session when no server event arrives for d.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(r *realtimeClient) {
+		r.idleTimeout = d
+	}
+}
+
+// WithSessionTimeout ends the session d after it is connected.
+func WithSessionTimeout(d time.Duration) Option {
+	return func(r *realtimeClient) {
+		r.sessionTimeout = d
+	}
+}
+
+// WithStallHandler calls fn when no server event arrives for d, once per
+// stall. The session is kept open.
+func WithStallHandler(d time.Duration, fn func(idle time.Duration)) Option {
+	return func(r *realtimeClient) {
+		r.stallAfter, r.onStall = d, fn
+	}
+}
+
+// WithToolTimeout bounds the execution of a registered tool, 30s by default.
+// A tool still running after d is answered with a timeout error and left to
+// finish on its own.
+func WithToolTimeout(d time.Duration) Option {
+	return func(r *realtimeClient) {
+		r.tools.timeout = d
+	}
+}
+
+// WithRecorder records every event sent and received with rec. The caller
+// owns rec and should close it after the session.
+func WithRecorder(rec *Recorder) Option {
+	return func(r *realtimeClient) {
+		r.recorder = rec
+	}
+}
+
+// WithLogger sets the logger of the client and its Dispatcher, slog.Default()
+// by default. Sent and received events are logged at debug level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *realtimeClient) {
+		r.logger = logger
+		r.Dispatcher.Logger = logger
+	}
+}
+
+// WithRateLimitThrottle sets what happens to response.create and input
+// appends while a rate limit reported by rate_limits.updated is exhausted.
+// The default is ThrottleOff.
+func WithRateLimitThrottle(mode ThrottleMode) Option {
+	return func(r *realtimeClient) {
+		r.limits.mode = mode
+	}
+}
+
+// WithRateLimitWarning calls fn when the remaining requests of a rate limit
+// drop below fraction of the limit, once until the limit recovers.
+func WithRateLimitWarning(fraction float64, fn func(limit events.RateLimit)) Option {
+	return func(r *realtimeClient) {
+		r.limits.warnBelow, r.limits.onLow = fraction, fn
+	}
+}
+
+// WithH264ParameterSets sets the base64 encoded SPS and PPS used by
+// SendFrameByVideo instead of the ones found in the stream.
+func WithH264ParameterSets(sps, pps string) Option {
+	return func(r *realtimeClient) {
+		r.paramSets.overrideSPS, r.paramSets.overridePPS = sps, pps
+	}
+}
+
+// WithFrameExtractor sets the FrameExtractor used by SendFrameByVideo, a
+// tools.FFmpegExtractor with its defaults otherwise.
+func WithFrameExtractor(e tools.FrameExtractor) Option {
+	return func(r *realtimeClient) {
+		r.extractor = e
+	}
+}
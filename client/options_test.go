@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestDialerOptionsSurviveWithDialer(t *testing.T) {
+	c := NewRealtimeClient("ws://localhost", "test", nil,
+		WithHandshakeTimeout(3*time.Second),
+		WithBufferSizes(1024, 2048),
+		WithCompression(true),
+		WithDialer(&websocket.Dialer{}),
+	)
+	d := c.dialer
+	if d.HandshakeTimeout != 3*time.Second || d.ReadBufferSize != 1024 || d.WriteBufferSize != 2048 || !d.EnableCompression {
+		t.Errorf("dialer %+v lost the options given before WithDialer", d)
+	}
+}
+
+func TestHeadersAndMaxMessageSize(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers <- req.Header.Clone()
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		big := &events.Event{Type: events.RealtimeServerEventResponseTextDelta, Delta: strings.Repeat("x", 2048)}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(big.ToJson()))
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	c := NewRealtimeClient("ws"+strings.TrimPrefix(srv.URL, "http"), "secret", nil,
+		WithHeader("X-Trace", "abc"),
+		WithMaxMessageSize(1024),
+	)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	header := <-headers
+	if got := header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := header.Get("X-Trace"); got != "abc" {
+		t.Errorf("X-Trace = %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitContext(ctx); err == nil || !strings.Contains(err.Error(), "read limit") {
+		t.Errorf("WaitContext() = %v, want the read limit exceeded", err)
+	}
+}
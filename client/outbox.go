@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// QueueFullPolicy decides what Send does when the outbound queue is full.
+type QueueFullPolicy int
+
+const (
+	// QueueFullBlock waits until there is room in the queue or ctx is done.
+	QueueFullBlock QueueFullPolicy = iota
+	// QueueFullDropOldestVideo drops the oldest queued video frame to make
+	// room, and blocks if no video frame is queued.
+	QueueFullDropOldestVideo
+	// QueueFullError fails with ErrQueueFull.
+	QueueFullError
+)
+
+// Priority of an outbound event, events with a higher priority are written
+// before queued events with a lower one. Events of the same priority keep
+// their order. Events of PriorityHigh or above are queued even if the queue
+// is full, so that control events never wait behind queued audio and video.
+type Priority int
+
+const (
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 10
+)
+
+const defaultQueueSize = 256
+
+var (
+	ErrQueueFull    = errors.New("outbound queue is full")
+	ErrFrameDropped = errors.New("video frame dropped from outbound queue")
+)
+
+// defaultPriorities lets control events overtake queued audio and video.
+var defaultPriorities = map[events.EventType]Priority{
+	events.RealtimeClientEventResponseCancel:           PriorityHigh,
+	events.RealtimeClientEventInputAudioBufferClear:    PriorityHigh,
+	events.RealtimeClientEventConversationItemTruncate: PriorityHigh,
+}
+
+type outboundMsg struct {
+	ctx      context.Context
+	event    *events.Event
+	data     []byte
+	priority Priority
+	done     chan error
+}
+
+func (m *outboundMsg) finish(err error) {
+	m.done <- err
+}
+
+// outbox is the bounded priority queue feeding the write pump.
+type outbox struct {
+	capacity int
+	policy   QueueFullPolicy
+
+	lock   sync.Mutex
+	queue  []*outboundMsg
+	ready  chan struct{} // signalled when a message is pushed
+	space  chan struct{} // closed when a message is popped
+	closed error
+}
+
+func newOutbox(capacity int, policy QueueFullPolicy) *outbox {
+	if capacity <= 0 {
+		capacity = defaultQueueSize
+	}
+	return &outbox{
+		capacity: capacity,
+		policy:   policy,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}),
+	}
+}
+
+func (o *outbox) push(ctx context.Context, msg *outboundMsg) error {
+	o.lock.Lock()
+	for {
+		if o.closed != nil {
+			o.lock.Unlock()
+			return o.closed
+		}
+		if msg.priority >= PriorityHigh || len(o.queue) < o.capacity || o.policy == QueueFullDropOldestVideo && o.dropOldestVideo() {
+			break
+		}
+		if o.policy == QueueFullError {
+			o.lock.Unlock()
+			return ErrQueueFull
+		}
+		space := o.space
+		o.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-space:
+		}
+		o.lock.Lock()
+	}
+
+	i := len(o.queue)
+	for i > 0 && o.queue[i-1].priority < msg.priority {
+		i--
+	}
+	o.queue = append(o.queue, nil)
+	copy(o.queue[i+1:], o.queue[i:])
+	o.queue[i] = msg
+	o.lock.Unlock()
+
+	select {
+	case o.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// dropOldestVideo must be called with o.lock held.
+func (o *outbox) dropOldestVideo() bool {
+	for i, msg := range o.queue {
+		if msg.event.Type == events.RealtimeClientVideoAppend {
+			o.queue = append(o.queue[:i], o.queue[i+1:]...)
+			msg.finish(ErrFrameDropped)
+			return true
+		}
+	}
+	return false
+}
+
+// pop blocks until a message is queued or ctx is done.
+func (o *outbox) pop(ctx context.Context) (*outboundMsg, error) {
+	for {
+		o.lock.Lock()
+		if o.closed != nil {
+			o.lock.Unlock()
+			return nil, o.closed
+		}
+		if len(o.queue) > 0 {
+			msg := o.queue[0]
+			o.queue = o.queue[1:]
+			close(o.space)
+			o.space = make(chan struct{})
+			o.lock.Unlock()
+			return msg, nil
+		}
+		o.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-o.ready:
+		}
+	}
+}
+
+// close fails all queued messages and later pushes with err.
+func (o *outbox) close(err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.closed != nil {
+		return
+	}
+	o.closed = err
+	for _, msg := range o.queue {
+		msg.finish(err)
+	}
+	o.queue = nil
+	close(o.space)
+}
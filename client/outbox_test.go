@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func newTestMsg(t events.EventType, p Priority) *outboundMsg {
+	return &outboundMsg{
+		ctx:      context.Background(),
+		event:    &events.Event{Type: t},
+		priority: p,
+		done:     make(chan error, 1),
+	}
+}
+
+func TestOutboxPriorityAndDrop(t *testing.T) {
+	ctx := context.Background()
+	out := newOutbox(3, QueueFullDropOldestVideo)
+
+	video := newTestMsg(events.RealtimeClientVideoAppend, PriorityNormal)
+	audio := newTestMsg(events.RealtimeClientEventInputAudioBufferAppend, PriorityNormal)
+	commit := newTestMsg(events.RealtimeClientEventInputAudioBufferCommit, PriorityNormal)
+	cancel := newTestMsg(events.RealtimeClientEventResponseCancel, PriorityHigh)
+	for _, msg := range []*outboundMsg{video, audio, cancel, commit} {
+		if err := out.push(ctx, msg); err != nil {
+			t.Fatalf("push %s: %v", msg.event.Type, err)
+		}
+	}
+	if err := <-video.done; !errors.Is(err, ErrFrameDropped) {
+		t.Fatalf("dropped frame finished with %v", err)
+	}
+
+	for _, want := range []*outboundMsg{cancel, audio, commit} {
+		got, err := out.pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("pop = %s, want %s", got.event.Type, want.event.Type)
+		}
+	}
+
+	full := newOutbox(1, QueueFullError)
+	_ = full.push(ctx, newTestMsg(events.RealtimeClientEventResponseCreate, PriorityNormal))
+	if err := full.push(ctx, newTestMsg(events.RealtimeClientEventResponseCreate, PriorityNormal)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("push on full queue = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestOutboxHighPriorityBypassesFullQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out := newOutbox(1, QueueFullBlock)
+	audio := newTestMsg(events.RealtimeClientEventInputAudioBufferAppend, PriorityNormal)
+	if err := out.push(ctx, audio); err != nil {
+		t.Fatal(err)
+	}
+	truncate := newTestMsg(events.RealtimeClientEventConversationItemTruncate, PriorityHigh)
+	if err := out.push(ctx, truncate); err != nil {
+		t.Fatalf("high priority push on full queue = %v", err)
+	}
+	if got, _ := out.pop(ctx); got != truncate {
+		t.Fatalf("pop = %s, want the truncate first", got.event.Type)
+	}
+
+	// Normal events still wait for room.
+	_ = out.push(ctx, truncate)
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if err := out.push(short, newTestMsg(events.RealtimeClientEventInputAudioBufferAppend, PriorityNormal)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("normal push on full queue = %v, want to block", err)
+	}
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// ThrottleMode decides what SendContext does with a throttled event while a
+// rate limit is exhausted.
+type ThrottleMode int
+
+const (
+	// ThrottleOff sends every event, leaving the limits to the server.
+	ThrottleOff ThrottleMode = iota
+	// ThrottleDelay holds the event until the exhausted limits reset.
+	ThrottleDelay
+	// ThrottleReject fails the send with a *RateLimitError.
+	ThrottleReject
+)
+
+// throttledTypes are the events held back while a rate limit is exhausted.
+var throttledTypes = map[events.EventType]bool{
+	events.RealtimeClientEventResponseCreate:         true,
+	events.RealtimeClientEventInputAudioBufferAppend: true,
+	events.RealtimeClientVideoAppend:                 true,
+}
+
+// RateLimitState is the last value of a rate limit reported by the server.
+type RateLimitState struct {
+	events.RateLimit
+	UpdatedAt time.Time
+}
+
+// ResetAt is the time the limit is replenished.
+func (s RateLimitState) ResetAt() time.Time {
+	return s.UpdatedAt.Add(time.Duration(float64(s.ResetSeconds) * float64(time.Second)))
+}
+
+// exhausted reports whether the limit has no requests left at now.
+func (s RateLimitState) exhausted(now time.Time) bool {
+	return s.Remaining <= 0 && now.Before(s.ResetAt())
+}
+
+// RateLimitError is returned by SendContext when ThrottleReject is set and
+// a rate limit is exhausted.
+type RateLimitError struct {
+	Name    string
+	ResetAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit %s exhausted until %s", e.Name, e.ResetAt.Format(time.RFC3339Nano))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+func (e *RateLimitError) Retryable() bool {
+	return true
+}
+
+// rateLimits keeps the limits of rate_limits.updated events.
+type rateLimits struct {
+	mode ThrottleMode
+	// warnBelow is the fraction of a limit under which onLow is called.
+	warnBelow float64
+	onLow     func(limit events.RateLimit)
+
+	lock   sync.RWMutex
+	limits map[string]RateLimitState
+	low    map[string]bool // limits onLow was called for
+}
+
+func (l *rateLimits) update(event *events.Event) {
+	if event.Type != events.RealtimeServerEventRateLimitsUpdated {
+		return
+	}
+	now := time.Now()
+	var warn []events.RateLimit
+	l.lock.Lock()
+	if l.limits == nil {
+		l.limits, l.low = make(map[string]RateLimitState), make(map[string]bool)
+	}
+	for _, limit := range event.RateLimits {
+		l.limits[limit.Name] = RateLimitState{RateLimit: limit, UpdatedAt: now}
+		isLow := limit.Limit > 0 && float64(limit.Remaining) < l.warnBelow*float64(limit.Limit)
+		if isLow && !l.low[limit.Name] {
+			warn = append(warn, limit)
+		}
+		l.low[limit.Name] = isLow
+	}
+	l.lock.Unlock()
+	// onLow runs without the lock, so that it may read the limits.
+	if l.onLow != nil {
+		for _, limit := range warn {
+			l.onLow(limit)
+		}
+	}
+}
+
+func (l *rateLimits) get(name string) (RateLimitState, bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	state, ok := l.limits[name]
+	return state, ok
+}
+
+func (l *rateLimits) all() map[string]RateLimitState {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	all := make(map[string]RateLimitState, len(l.limits))
+	for name, state := range l.limits {
+		all[name] = state
+	}
+	return all
+}
+
+// exhausted returns the exhausted limit resetting last, if any.
+func (l *rateLimits) exhausted(now time.Time) (RateLimitState, bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	var last RateLimitState
+	found := false
+	for _, state := range l.limits {
+		if state.exhausted(now) && (!found || state.ResetAt().After(last.ResetAt())) {
+			last, found = state, true
+		}
+	}
+	return last, found
+}
+
+// throttle applies the ThrottleMode to an event of type t before it is queued.
+func (l *rateLimits) throttle(ctx context.Context, t events.EventType) error {
+	if l.mode == ThrottleOff || !throttledTypes[t] {
+		return nil
+	}
+	for {
+		state, ok := l.exhausted(time.Now())
+		if !ok {
+			return nil
+		}
+		if l.mode == ThrottleReject {
+			return &RateLimitError{Name: state.Name, ResetAt: state.ResetAt()}
+		}
+		// A new rate_limits.updated may change the reset time, so the limits
+		// are checked again after waiting.
+		if err := sleepUntil(ctx, state.ResetAt()); err != nil {
+			return err
+		}
+	}
+}
+
+// RateLimits returns the last rate limits reported by the server by name.
+func (r *realtimeClient) RateLimits() map[string]RateLimitState {
+	return r.limits.all()
+}
+
+// RateLimit returns the last state of the rate limit name.
+func (r *realtimeClient) RateLimit(name string) (RateLimitState, bool) {
+	return r.limits.get(name)
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func rateLimitsUpdated(limits ...events.RateLimit) *events.Event {
+	return &events.Event{Type: events.RealtimeServerEventRateLimitsUpdated, RateLimits: limits}
+}
+
+func TestRateLimitThrottle(t *testing.T) {
+	var warned []string
+	l := &rateLimits{mode: ThrottleReject, warnBelow: 0.2, onLow: func(limit events.RateLimit) {
+		warned = append(warned, limit.Name)
+	}}
+	l.update(rateLimitsUpdated(events.RateLimit{Name: "requests", Limit: 10, Remaining: 1, ResetSeconds: 0.1}))
+	l.update(rateLimitsUpdated(events.RateLimit{Name: "requests", Limit: 10, Remaining: 0, ResetSeconds: 0.1}))
+	if len(warned) != 1 {
+		t.Errorf("warned %v, want once while the limit stays low", warned)
+	}
+
+	ctx := context.Background()
+	err := l.throttle(ctx, events.RealtimeClientEventResponseCreate)
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Name != "requests" || !errors.Is(err, ErrRateLimited) || !IsRetryable(err) {
+		t.Fatalf("throttle error = %v, want a RateLimitError for requests", err)
+	}
+	if err = l.throttle(ctx, events.RealtimeClientEventSessionUpdate); err != nil {
+		t.Errorf("session.update throttled: %v", err)
+	}
+
+	l.mode = ThrottleDelay
+	start := time.Now()
+	if err = l.throttle(ctx, events.RealtimeClientEventInputAudioBufferAppend); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("append sent after %v, want it held until the reset", waited)
+	}
+}
+
+func TestRateLimitsFromServer(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	received := make(chan struct{})
+	c.OnRateLimitsUpdated(func(*events.Event) error {
+		close(received)
+		return nil
+	})
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	if err := srv.Send(rateLimitsUpdated(events.RateLimit{Name: "tokens", Limit: 1000, Remaining: 750, ResetSeconds: 30})); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rate_limits.updated not received")
+	}
+	state, ok := c.RateLimit("tokens")
+	if !ok || state.Remaining != 750 || time.Until(state.ResetAt()) <= 0 {
+		t.Errorf("RateLimit(tokens) = %+v, %v", state, ok)
+	}
+	if len(c.RateLimits()) != 1 {
+		t.Errorf("RateLimits() = %v", c.RateLimits())
+	}
+}
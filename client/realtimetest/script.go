@@ -0,0 +1,45 @@
+package realtimetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// LoadScript reads a JSONL file of server events, see ParseScript.
+func LoadScript(path string) ([]*events.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open script failed: %v", err)
+	}
+	defer file.Close()
+	return ParseScript(file)
+}
+
+// ParseScript reads one event per line. Empty lines and lines starting with
+// "//" are skipped, like in the Realtime .Input files.
+func ParseScript(r io.Reader) ([]*events.Event, error) {
+	var script []*events.Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		event := &events.Event{}
+		if err := json.Unmarshal([]byte(line), event); err != nil {
+			return nil, fmt.Errorf("line %d: unmarshal event failed: %v", lineNo, err)
+		}
+		script = append(script, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read script failed: %v", err)
+	}
+	return script, nil
+}
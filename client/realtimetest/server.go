@@ -0,0 +1,295 @@
+// Package realtimetest provides a local GLM-Realtime server for testing code
+// built on the client package.
+package realtimetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// Server is a WebSocket server speaking the event protocol of the events
+// package. It acknowledges session, buffer and conversation events, answers
+// each response.create with the next queued response script and records
+// every client event it receives.
+type Server struct {
+	// URL is the ws:// URL of the server.
+	URL string
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	lock      sync.Mutex
+	conns     map[*serverConn]struct{}
+	received  []*events.Event
+	notify    chan struct{} // closed and replaced when an event is received
+	responses [][]*events.Event
+	rejects   map[events.EventType]*events.EventError
+	nextID    int
+	lastItem  string // id of the last item of the conversation
+}
+
+type serverConn struct {
+	conn *websocket.Conn
+	lock sync.Mutex
+}
+
+func (c *serverConn) write(event *events.Event) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, []byte(event.ToJson()))
+}
+
+// NewServer starts a Server, callers should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		conns:   make(map[*serverConn]struct{}),
+		notify:  make(chan struct{}),
+		rejects: make(map[events.EventType]*events.EventError),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveWs))
+	s.URL = "ws" + strings.TrimPrefix(s.srv.URL, "http")
+	return s
+}
+
+// Close closes all connections and shuts down the server.
+func (s *Server) Close() {
+	s.DropConnections()
+	s.srv.Close()
+}
+
+// DropConnections closes the underlying connections without a close frame,
+// like a network failure would.
+func (s *Server) DropConnections() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for c := range s.conns {
+		_ = c.conn.Close()
+	}
+}
+
+// QueueResponse queues a script of server events that is played for the next
+// response.create. Without a queued script a minimal response is played.
+func (s *Server) QueueResponse(script []*events.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.responses = append(s.responses, script)
+}
+
+// RejectNext answers the next client event of type t with an error event
+// carrying e and the event_id of the client event, instead of the usual reply.
+func (s *Server) RejectNext(t events.EventType, e events.EventError) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rejects[t] = &e
+}
+
+// Send sends event to all connected clients.
+func (s *Server) Send(event *events.Event) error {
+	s.lock.Lock()
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.lock.Unlock()
+	for _, c := range conns {
+		if err := c.write(s.withEventID(event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Received returns the client events received so far, in order.
+func (s *Server) Received() []*events.Event {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*events.Event(nil), s.received...)
+}
+
+// ReceivedOfType returns the received client events of type t.
+func (s *Server) ReceivedOfType(t events.EventType) []*events.Event {
+	var result []*events.Event
+	for _, event := range s.Received() {
+		if event.Type == t {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// WaitFor blocks until a client event of type t has been received or ctx is
+// done, and returns the first such event.
+func (s *Server) WaitFor(ctx context.Context, t events.EventType) (*events.Event, error) {
+	for {
+		s.lock.Lock()
+		for _, event := range s.received {
+			if event.Type == t {
+				s.lock.Unlock()
+				return event, nil
+			}
+		}
+		notify := s.notify
+		s.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for %s: %w", t, ctx.Err())
+		case <-notify:
+		}
+	}
+}
+
+func (s *Server) serveWs(w http.ResponseWriter, req *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		slog.Warn("Upgrade failed", "component", "realtimetest", "err", err)
+		return
+	}
+	c := &serverConn{conn: conn}
+	s.lock.Lock()
+	s.conns[c] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, c)
+		s.lock.Unlock()
+		_ = conn.Close()
+	}()
+
+	if err = c.write(s.withEventID(&events.Event{
+		Type:    events.RealtimeServerEventSessionCreated,
+		Session: &events.Session{ID: "sess_mock", Object: "realtime.session"},
+	})); err != nil {
+		return
+	}
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		event := &events.Event{}
+		if err = json.Unmarshal(message, event); err != nil {
+			slog.Warn("Unmarshal failed", "component", "realtimetest", "err", err)
+			continue
+		}
+		s.record(event)
+		for _, reply := range s.reply(event) {
+			if err = c.write(s.withEventID(reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) record(event *events.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.received = append(s.received, event)
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// reply returns the server events answering the client event.
+func (s *Server) reply(event *events.Event) []*events.Event {
+	s.lock.Lock()
+	reject := s.rejects[event.Type]
+	delete(s.rejects, event.Type)
+	s.lock.Unlock()
+	if reject != nil {
+		reject.EventID = event.EventID
+		return []*events.Event{{Type: events.RealtimeServerEventError, Error: reject}}
+	}
+
+	switch event.Type {
+	case events.RealtimeClientEventSessionUpdate:
+		session := &events.Session{}
+		if event.Session != nil {
+			*session = *event.Session
+		}
+		session.ID, session.Object = "sess_mock", "realtime.session"
+		return []*events.Event{{Type: events.RealtimeServerEventSessionUpdated, Session: session}}
+	case events.RealtimeClientEventInputAudioBufferCommit:
+		return []*events.Event{{Type: events.RealtimeServerEventInputAudioBufferCommitted, ItemID: s.newID("item")}}
+	case events.RealtimeClientEventInputAudioBufferClear:
+		return []*events.Event{{Type: events.RealtimeServerEventInputAudioBufferCleared}}
+	case events.RealtimeClientEventConversationItemCreate:
+		item := &events.Item{}
+		if event.Item != nil {
+			*item = *event.Item
+		}
+		if item.ID == "" {
+			item.ID = s.newID("item")
+		}
+		item.Object = events.ItemObjectRealTimeItem
+		// Like the server, report the actual predecessor of an item appended
+		// without previous_item_id.
+		previousID := event.PreviousItemID
+		s.lock.Lock()
+		if previousID == "" {
+			previousID = s.lastItem
+		}
+		if previousID == s.lastItem {
+			s.lastItem = item.ID
+		}
+		s.lock.Unlock()
+		return []*events.Event{{Type: events.RealtimeServerEventConversationItemCreated, PreviousItemID: previousID, Item: item}}
+	case events.RealtimeClientEventConversationItemDelete:
+		return []*events.Event{{Type: events.RealtimeServerEventConversationItemDeleted, ItemID: event.ItemID}}
+	case events.RealtimeClientEventConversationItemTruncate:
+		return []*events.Event{{
+			Type:         events.RealtimeServerEventConversationItemTruncated,
+			ItemID:       event.ItemID,
+			ContentIndex: event.ContentIndex,
+			AudioEndMS:   event.AudioEndMS,
+		}}
+	case events.RealtimeClientEventResponseCreate:
+		return s.nextResponse()
+	}
+	return nil
+}
+
+func (s *Server) nextResponse() []*events.Event {
+	s.lock.Lock()
+	if len(s.responses) > 0 {
+		script := s.responses[0]
+		s.responses = s.responses[1:]
+		s.lock.Unlock()
+		return script
+	}
+	s.lock.Unlock()
+
+	id := s.newID("resp")
+	return []*events.Event{
+		{Type: events.RealtimeServerEventResponseCreated, Response: &events.Response{
+			ID: id, Object: events.ResponseObjectResponse, Status: events.ResponseStatusInProgress,
+		}},
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{
+			ID: id, Object: events.ResponseObjectResponse, Status: events.ResponseStatusCompleted,
+		}},
+	}
+}
+
+func (s *Server) newID(prefix string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nextID++
+	return fmt.Sprintf("%s_mock_%d", prefix, s.nextID)
+}
+
+// withEventID returns a copy of event with an event_id set if it has none.
+func (s *Server) withEventID(event *events.Event) *events.Event {
+	if event.EventID != "" {
+		return event
+	}
+	e := *event
+	e.EventID = s.newID("event")
+	return &e
+}
@@ -0,0 +1,76 @@
+package realtimetest_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestServerScript(t *testing.T) {
+	script, err := realtimetest.LoadScript(filepath.Join("testdata", "text_response.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.QueueResponse(script)
+
+	var text strings.Builder
+	updated := make(chan struct{}, 1)
+	done := make(chan *events.Event, 1)
+	c := client.NewRealtimeClient(srv.URL, "test", nil)
+	c.OnSessionUpdated(func(event *events.Event) error {
+		updated <- struct{}{}
+		return nil
+	})
+	c.OnTextDelta(func(event *events.Event) error {
+		text.WriteString(event.Delta)
+		return nil
+	})
+	c.OnResponseDone(func(event *events.Event) error {
+		done <- event
+		return nil
+	})
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, event := range []*events.Event{
+		{Type: events.RealtimeClientEventSessionUpdate, Session: &events.Session{Modalities: []events.Modality{events.ModalityText}}},
+		{Type: events.RealtimeClientEventInputAudioBufferCommit},
+		{Type: events.RealtimeClientEventResponseCreate},
+	} {
+		if err = c.SendContext(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-updated:
+	case <-ctx.Done():
+		t.Fatal("session.updated not received")
+	}
+	select {
+	case event := <-done:
+		if event.Response.Usage == nil || event.Response.Usage.TotalTokens != 12 {
+			t.Errorf("unexpected usage: %+v", event.Response.Usage)
+		}
+	case <-ctx.Done():
+		t.Fatal("response.done not received")
+	}
+	if got := text.String(); got != "你好，世界" {
+		t.Errorf("text = %q", got)
+	}
+	if got := len(srv.ReceivedOfType(events.RealtimeClientEventResponseCreate)); got != 1 {
+		t.Errorf("received %d response.create, want 1", got)
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ConnState is the state of the connection of a RealtimeClient.
+type ConnState int
+
+const (
+	StateClosed ConnState = iota
+	StateConnecting
+	StateConnected
+	StateReconnecting
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	default:
+		return "unknown"
+	}
+}
+
+// ReconnectPolicy controls how the client re-establishes a connection lost
+// because of a transport error. Zero fields other than Jitter fall back to
+// DefaultReconnectPolicy.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of dials tried before giving up, negative means unlimited.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction (0~1) of each backoff that is randomized, 0 keeps
+	// the backoff deterministic.
+	Jitter float64
+}
+
+// replayWriteTimeout bounds the write of the session.update replayed after a
+// reconnect.
+const replayWriteTimeout = 10 * time.Second
+
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultReconnectPolicy.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultReconnectPolicy.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultReconnectPolicy.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultReconnectPolicy.Multiplier
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	return p
+}
+
+// backoff returns the delay before the given attempt, starting from 0.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	d -= d * p.Jitter * rand.Float64()
+	return time.Duration(d)
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// stateRecorder collects the states reported to WithStateHandler.
+type stateRecorder struct {
+	lock   sync.Mutex
+	states []ConnState
+	onNext func(state ConnState)
+}
+
+func (s *stateRecorder) handle(state ConnState, _ error) {
+	s.lock.Lock()
+	s.states = append(s.states, state)
+	onNext := s.onNext
+	s.lock.Unlock()
+	if onNext != nil {
+		onNext(state)
+	}
+}
+
+func (s *stateRecorder) get() []ConnState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]ConnState(nil), s.states...)
+}
+
+func TestReconnect(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	states := &stateRecorder{}
+	policy := ReconnectPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+	c := NewRealtimeClient(srv.URL, "test", nil, WithReconnect(policy), WithStateHandler(states.handle))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.UpdateSession(ctx, &events.Session{Instructions: "be brief"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An event sent while reconnecting stays queued and is written after the
+	// replayed session.update.
+	queued := make(chan error, 1)
+	states.lock.Lock()
+	states.onNext = func(state ConnState) {
+		if state == StateReconnecting {
+			go func() {
+				queued <- c.SendContext(ctx, &events.Event{Type: events.RealtimeClientEventInputAudioBufferCommit})
+			}()
+		}
+	}
+	states.lock.Unlock()
+	srv.DropConnections()
+	select {
+	case err := <-queued:
+		if err != nil {
+			t.Fatalf("event queued during the reconnect: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("event queued during the reconnect was not sent")
+	}
+	if _, err := srv.WaitFor(ctx, events.RealtimeClientEventInputAudioBufferCommit); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ConnState{StateConnecting, StateConnected, StateReconnecting, StateConnected}
+	if got := states.get(); !slices.Equal(got, want) {
+		t.Errorf("states %v, want %v", got, want)
+	}
+	var types []events.EventType
+	for _, event := range srv.Received() {
+		types = append(types, event.Type)
+	}
+	wantTypes := []events.EventType{
+		events.RealtimeClientEventSessionUpdate,
+		events.RealtimeClientEventSessionUpdate,
+		events.RealtimeClientEventInputAudioBufferCommit,
+	}
+	if !slices.Equal(types, wantTypes) {
+		t.Errorf("received %v, want %v", types, wantTypes)
+	}
+	if updates := srv.ReceivedOfType(events.RealtimeClientEventSessionUpdate); updates[1].Session == nil || updates[1].Session.Instructions != "be brief" {
+		t.Errorf("replayed session.update %+v", updates[1])
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	srv := realtimetest.NewServer()
+	states := &stateRecorder{}
+	policy := ReconnectPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+	c := NewRealtimeClient(srv.URL, "test", nil, WithReconnect(policy), WithStateHandler(states.handle))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing the server drops the connection and refuses new ones.
+	srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := c.WaitContext(ctx)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("WaitContext() = %v, want the reconnect to give up after 2 attempts", err)
+	}
+	want := []ConnState{StateConnecting, StateConnected, StateReconnecting, StateClosed}
+	if got := states.get(); !slices.Equal(got, want) {
+		t.Errorf("states %v, want %v", got, want)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	policy := ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}.withDefaults()
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := policy.backoff(attempt); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %v without jitter, want %v", attempt, got, want*time.Millisecond)
+		}
+	}
+	policy.Jitter = 0.5
+	for range 100 {
+		if got := policy.backoff(0); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("backoff(0) = %v with jitter 0.5, want 50ms~100ms", got)
+		}
+	}
+}
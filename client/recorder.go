@@ -0,0 +1,187 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// Direction tells whether a recorded event was sent or received by the client.
+type Direction string
+
+const (
+	DirectionOut Direction = "out"
+	DirectionIn  Direction = "in"
+)
+
+// RecorderOptions configures a Recorder.
+type RecorderOptions struct {
+	// MaxBytes starts a new file once the current one exceeds it, 0 disables rotation.
+	MaxBytes int64
+	// MaxFiles is the number of files kept, older ones are removed. 0 keeps all.
+	MaxFiles int
+	// StripAudio empties the base64 audio of input_audio_buffer.append and
+	// response.audio.delta events.
+	StripAudio bool
+	// StripVideo empties the video_frame of video frame events.
+	StripVideo bool
+}
+
+// Recorder writes the events of a session to a file in the line-oriented
+// format of the Realtime .Input files, one JSON event per line. Every event
+// gets a "direction" field and an "elapsed_ms" field holding the monotonic
+// time since the recorder was created. Rotated files are named by inserting
+// the file index before the extension, e.g. Session.1.Input.
+type Recorder struct {
+	path  string
+	opts  RecorderOptions
+	start time.Time
+
+	lock  sync.Mutex
+	file  *os.File
+	size  int64
+	lines int // events written to file
+	index int
+	// lastSessionUpdate is written at the top of every rotated file.
+	lastSessionUpdate []byte
+}
+
+func NewRecorder(path string, opts RecorderOptions) (*Recorder, error) {
+	r := &Recorder{path: path, opts: opts, start: time.Now()}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) fileName(index int) string {
+	if index == 0 {
+		return r.path
+	}
+	ext := filepath.Ext(r.path)
+	return strings.TrimSuffix(r.path, ext) + "." + strconv.Itoa(index) + ext
+}
+
+// open must be called with r.lock held.
+func (r *Recorder) open() error {
+	file, err := os.Create(r.fileName(r.index))
+	if err != nil {
+		return fmt.Errorf("create record file failed: %v", err)
+	}
+	r.file, r.size, r.lines = file, 0, 0
+	header := fmt.Sprintf("// recorded by RealtimeClient at %s, part %d\n", time.Now().Format(time.RFC3339), r.index)
+	if err = r.write([]byte(header)); err != nil {
+		return err
+	}
+	if r.lastSessionUpdate != nil {
+		return r.write(r.lastSessionUpdate)
+	}
+	return nil
+}
+
+// rotate must be called with r.lock held.
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("close record file failed: %v", err)
+	}
+	r.index++
+	if r.opts.MaxFiles > 0 && r.index >= r.opts.MaxFiles {
+		_ = os.Remove(r.fileName(r.index - r.opts.MaxFiles))
+	}
+	return r.open()
+}
+
+func (r *Recorder) write(line []byte) error {
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write record file failed: %v", err)
+	}
+	return nil
+}
+
+// Record writes event. raw, if not nil, is the JSON of event as it was sent
+// or received and is written instead of re-encoding event.
+func (r *Recorder) Record(direction Direction, event *events.Event, raw []byte) error {
+	if r.strips(event) {
+		stripped := *event
+		if r.opts.StripVideo {
+			stripped.VideoFrame = nil
+		}
+		if r.opts.StripAudio {
+			stripped.Audio = ""
+			if stripped.Type == events.RealtimeServerEventResponseAudioDelta {
+				stripped.Delta = ""
+			}
+		}
+		event, raw = &stripped, nil
+	}
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(event); err != nil {
+			return fmt.Errorf("marshal event failed: %v", err)
+		}
+	}
+	if len(raw) < 2 || raw[0] != '{' {
+		return fmt.Errorf("event is not a JSON object")
+	}
+	body := raw[1:]
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return fmt.Errorf("recorder closed")
+	}
+	// elapsed_ms is taken under the lock so that it grows with the file.
+	line := make([]byte, 0, len(body)+48)
+	line = fmt.Appendf(line, `{"direction":%q,"elapsed_ms":%d`, direction, time.Since(r.start).Milliseconds())
+	if body[0] != '}' {
+		line = append(line, ',')
+	}
+	line = append(line, body...)
+	line = append(line, '\n')
+	if r.opts.MaxBytes > 0 && r.size+int64(len(line)) > r.opts.MaxBytes && r.lines > 0 {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	r.lines++
+	if err := r.write(line); err != nil {
+		return err
+	}
+	// Set only now so that a rotation caused by this line does not write it
+	// twice to the new file.
+	if direction == DirectionOut && event.Type == events.RealtimeClientEventSessionUpdate {
+		r.lastSessionUpdate = line
+	}
+	return nil
+}
+
+func (r *Recorder) strips(event *events.Event) bool {
+	switch event.Type {
+	case events.RealtimeClientVideoAppend:
+		return r.opts.StripVideo
+	case events.RealtimeClientEventInputAudioBufferAppend, events.RealtimeServerEventResponseAudioDelta:
+		return r.opts.StripAudio
+	}
+	return false
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// recordedLines returns the events of a record file, skipping comments.
+func recordedLines(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "//") {
+			continue
+		}
+		line := map[string]any{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("%s: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRecorderRotation(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(filepath.Join(dir, "Session.Input"), RecorderOptions{MaxBytes: 200, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Close()
+
+	update := func(instructions string) *events.Event {
+		return &events.Event{Type: events.RealtimeClientEventSessionUpdate, Session: &events.Session{Instructions: instructions}}
+	}
+	clearBuffer := &events.Event{Type: events.RealtimeClientEventInputAudioBufferClear}
+	// Every event after the first one starts a new file.
+	for _, event := range []*events.Event{update("first"), update("second"), clearBuffer, clearBuffer} {
+		if err := rec.Record(DirectionOut, event, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "Session.Input")); !os.IsNotExist(err) {
+		t.Errorf("file 0 not pruned with MaxFiles 3: %v", err)
+	}
+	// describe returns the instructions of session.update lines, the type of others.
+	describe := func(line map[string]any) string {
+		if session, ok := line["session"].(map[string]any); ok {
+			return session["instructions"].(string)
+		}
+		return line["type"].(string)
+	}
+	for name, want := range map[string][]string{
+		// The session.update causing a rotation follows the replayed one once.
+		"Session.1.Input": {"first", "second"},
+		"Session.2.Input": {"second", "input_audio_buffer.clear"},
+		"Session.3.Input": {"second", "input_audio_buffer.clear"},
+	} {
+		var got []string
+		for _, line := range recordedLines(t, filepath.Join(dir, name)) {
+			got = append(got, describe(line))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s holds %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRecorderStripAndPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Session.Input")
+	rec, err := NewRecorder(path, RecorderOptions{StripAudio: true, StripVideo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []struct {
+		direction Direction
+		event     *events.Event
+	}{
+		{DirectionOut, &events.Event{Type: events.RealtimeClientEventInputAudioBufferAppend, Audio: "AAAA"}},
+		{DirectionOut, &events.Event{Type: events.RealtimeClientVideoAppend, VideoFrame: []byte("jpeg")}},
+		{DirectionIn, &events.Event{Type: events.RealtimeServerEventResponseAudioDelta, Delta: "AAAA"}},
+		{DirectionIn, &events.Event{Type: events.RealtimeServerEventResponseTextDelta, Delta: "hi"}},
+	} {
+		if err := rec.Record(record.direction, record.event, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := recordedLines(t, path)
+	if len(lines) != 4 {
+		t.Fatalf("%d lines, want 4", len(lines))
+	}
+	for i, direction := range []Direction{DirectionOut, DirectionOut, DirectionIn, DirectionIn} {
+		if lines[i]["direction"] != string(direction) {
+			t.Errorf("line %d direction %v, want %s", i, lines[i]["direction"], direction)
+		}
+		if _, ok := lines[i]["elapsed_ms"].(float64); !ok {
+			t.Errorf("line %d has no elapsed_ms: %v", i, lines[i])
+		}
+	}
+	if _, ok := lines[0]["audio"]; ok {
+		t.Errorf("audio not stripped: %v", lines[0])
+	}
+	if _, ok := lines[1]["video_frame"]; ok {
+		t.Errorf("video frame not stripped: %v", lines[1])
+	}
+	if lines[2]["delta"] != "" || lines[3]["delta"] != "hi" {
+		t.Errorf("deltas %q and %q, want the audio delta stripped only", lines[2]["delta"], lines[3]["delta"])
+	}
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+// Pacing decides when Replay sends the next event.
+type Pacing int
+
+const (
+	// PacingNone sends the events as fast as possible.
+	PacingNone Pacing = iota
+	// PacingOriginal reproduces the recorded timing, taken from elapsed_ms
+	// written by the Recorder or else from client_timestamp.
+	PacingOriginal
+	// PacingAudio sends input audio in real time, every event following an
+	// input_audio_buffer.append waits for the duration of its audio.
+	PacingAudio
+)
+
+const defaultReplaySampleRate = 16000
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	Pacing Pacing
+	// SampleRate of the 16 bit mono PCM input audio, used by PacingAudio.
+	// The default is 16000.
+	SampleRate int
+	// WaitResponses waits after the last event until a response.done was
+	// received for every response.create sent.
+	WaitResponses bool
+}
+
+// ReplayResult holds the client events sent and the server events received
+// during a replay.
+type ReplayResult struct {
+	Sent     []*events.Event
+	Received []*events.Event
+}
+
+// ReplayClient is the client a replay is sent through.
+type ReplayClient interface {
+	RealtimeClient
+	OnAny(h Handler) (remove func())
+}
+
+type replayLine struct {
+	Direction Direction `json:"direction"`
+	ElapsedMS *int64    `json:"elapsed_ms"`
+}
+
+// ReplayFile replays the client events of a .Input file, see Replay.
+func ReplayFile(ctx context.Context, c ReplayClient, path string, opts ReplayOptions) (*ReplayResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file failed: %v", err)
+	}
+	defer file.Close()
+	return Replay(ctx, c, file, opts)
+}
+
+// Replay sends the client events read from r, in the format of the Realtime
+// .Input files, through the connected client c and collects the server events
+// received meanwhile. Comment lines, server events recorded by a Recorder and
+// events whose audio or video was stripped are skipped.
+func Replay(ctx context.Context, c ReplayClient, r io.Reader, opts ReplayOptions) (*ReplayResult, error) {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = defaultReplaySampleRate
+	}
+	result := &ReplayResult{}
+	var (
+		lock     sync.Mutex
+		received []*events.Event
+		// done stops the collection: Dispatch may still run the handler for
+		// an event in flight after it is removed.
+		done bool
+	)
+	responsesDone := make(chan struct{}, 1)
+	remove := c.OnAny(func(event *events.Event) error {
+		lock.Lock()
+		if done {
+			lock.Unlock()
+			return nil
+		}
+		received = append(received, event)
+		lock.Unlock()
+		if event.Type == events.RealtimeServerEventResponseDone {
+			select {
+			case responsesDone <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	})
+	defer func() {
+		remove()
+		lock.Lock()
+		done = true
+		result.Received = received
+		lock.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 10*1024*1024), 10*1024*1024)
+	start := time.Now()
+	var firstTime *int64 // recorded time of the first event sent
+	var audioOffset time.Duration
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		// 跳过空行和注释
+		if !strings.HasPrefix(string(line), "{") {
+			continue
+		}
+		var meta replayLine
+		event := &events.Event{}
+		if err := json.Unmarshal(line, &meta); err != nil {
+			return result, fmt.Errorf("line %d: unmarshal failed: %v", lineNo, err)
+		}
+		if err := json.Unmarshal(line, event); err != nil {
+			return result, fmt.Errorf("line %d: unmarshal failed: %v", lineNo, err)
+		}
+		if meta.Direction == DirectionIn || stripped(event) {
+			continue
+		}
+
+		var at time.Duration
+		switch opts.Pacing {
+		case PacingOriginal:
+			recorded := meta.ElapsedMS
+			if recorded == nil && event.ClientTimestamp > 0 {
+				recorded = &event.ClientTimestamp
+			}
+			if recorded != nil {
+				if firstTime == nil {
+					firstTime = recorded
+				}
+				at = time.Duration(*recorded-*firstTime) * time.Millisecond
+			}
+		case PacingAudio:
+			at = audioOffset
+		}
+		if err := sleepUntil(ctx, start.Add(at)); err != nil {
+			return result, err
+		}
+
+		event.ClientTimestamp = 0
+		if err := c.SendContext(ctx, event); err != nil {
+			return result, fmt.Errorf("line %d: send %s failed: %w", lineNo, event.Type, err)
+		}
+		result.Sent = append(result.Sent, event)
+		if event.Type == events.RealtimeClientEventInputAudioBufferAppend {
+			audioOffset += audioDuration(event.Audio, opts.SampleRate)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("read file failed: %v", err)
+	}
+
+	if opts.WaitResponses {
+		want := 0
+		for _, event := range result.Sent {
+			if event.Type == events.RealtimeClientEventResponseCreate {
+				want++
+			}
+		}
+		for {
+			lock.Lock()
+			got := 0
+			for _, event := range received {
+				if event.Type == events.RealtimeServerEventResponseDone {
+					got++
+				}
+			}
+			lock.Unlock()
+			if got >= want {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return result, context.Cause(ctx)
+			case <-responsesDone:
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// stripped reports whether the payload of event was removed by a Recorder.
+func stripped(event *events.Event) bool {
+	switch event.Type {
+	case events.RealtimeClientEventInputAudioBufferAppend:
+		return event.Audio == ""
+	case events.RealtimeClientVideoAppend:
+		return len(event.VideoFrame) == 0
+	}
+	return false
+}
+
+// audioDuration returns the duration of base64 encoded 16 bit mono PCM.
+func audioDuration(audio string, sampleRate int) time.Duration {
+	bytes := base64.StdEncoding.DecodedLen(len(audio)) - strings.Count(audio[max(len(audio)-2, 0):], "=")
+	return time.Duration(bytes) * time.Second / time.Duration(sampleRate*2)
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return context.Cause(ctx)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestReplaySampleInput(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	input := filepath.Join("..", "samples", "files", "Video.ClientVad.Input")
+	result, err := ReplayFile(ctx, c, input, ReplayOptions{WaitResponses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[events.EventType]int{}
+	for _, event := range srv.Received() {
+		counts[event.Type]++
+	}
+	want := map[events.EventType]int{
+		events.RealtimeClientEventSessionUpdate:          1, // the commented one is skipped
+		events.RealtimeClientEventInputAudioBufferAppend: 5,
+		events.RealtimeClientVideoAppend:                 2,
+		events.RealtimeClientEventInputAudioBufferCommit: 1,
+		events.RealtimeClientEventResponseCreate:         1,
+	}
+	for eventType, n := range want {
+		if counts[eventType] != n {
+			t.Errorf("server received %d %s, want %d", counts[eventType], eventType, n)
+		}
+	}
+	if len(result.Sent) != 10 {
+		t.Errorf("sent %d events, want 10", len(result.Sent))
+	}
+	var done bool
+	for _, event := range result.Received {
+		done = done || event.Type == events.RealtimeServerEventResponseDone
+	}
+	if !done {
+		t.Error("response.done not collected")
+	}
+}
+
+// lateClient delivers an event to the replay handler after Replay returned,
+// as Dispatch does for an event in flight when the handler is removed.
+type lateClient struct {
+	RealtimeClient
+	handler Handler
+}
+
+func (c *lateClient) SendContext(context.Context, *events.Event) error {
+	return c.handler(&events.Event{Type: events.RealtimeServerEventInputAudioBufferCleared})
+}
+
+func (c *lateClient) OnAny(h Handler) func() {
+	c.handler = h
+	return func() {}
+}
+
+func TestReplayIgnoresLateEvents(t *testing.T) {
+	c := &lateClient{}
+	input := `{"type":"input_audio_buffer.clear"}` + "\n"
+	result, err := Replay(context.Background(), c, strings.NewReader(input), ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.handler(&events.Event{Type: events.RealtimeServerEventResponseDone}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Sent) != 1 || len(result.Received) != 1 {
+		t.Errorf("sent %d, received %d events, want 1 each and the late event ignored", len(result.Sent), len(result.Received))
+	}
+}
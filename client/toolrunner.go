@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+const defaultToolTimeout = 30 * time.Second
+
+var errToolTimedOut = errors.New("tool timed out")
+
+// ToolFunc executes a function call, arguments is the JSON object sent by the
+// model. The result is returned to the model as is if it is a string, as JSON
+// otherwise.
+type ToolFunc func(ctx context.Context, arguments json.RawMessage) (any, error)
+
+// TypedTool adapts fn to a ToolFunc decoding the arguments into T.
+func TypedTool[T any](fn func(ctx context.Context, args T) (any, error)) ToolFunc {
+	return func(ctx context.Context, arguments json.RawMessage) (any, error) {
+		var args T
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, fmt.Errorf("decode arguments failed: %v", err)
+		}
+		return fn(ctx, args)
+	}
+}
+
+type toolCall struct {
+	outputIndex int
+	callID      string
+	output      string
+	done        chan struct{}
+}
+
+// toolRunner executes the function calls of a response with the registered
+// tools. Once the response is done, it sends a function_call_output item per
+// call and a single response.create.
+type toolRunner struct {
+	client  *realtimeClient
+	timeout time.Duration
+
+	lock  sync.Mutex
+	tools map[string]ToolFunc
+	calls map[string][]*toolCall // by response id
+}
+
+func newToolRunner(r *realtimeClient) *toolRunner {
+	t := &toolRunner{
+		client:  r,
+		timeout: defaultToolTimeout,
+		tools:   make(map[string]ToolFunc),
+		calls:   make(map[string][]*toolCall),
+	}
+	r.OnFunctionCallArgumentsDone(t.onArgumentsDone)
+	r.OnResponseDone(t.onResponseDone)
+	return t
+}
+
+// RegisterTool registers fn as the tool called name. Once a tool is
+// registered, function calls are answered automatically; calls to unknown
+// tools are answered with an error.
+func (r *realtimeClient) RegisterTool(name string, fn ToolFunc) {
+	r.tools.lock.Lock()
+	defer r.tools.lock.Unlock()
+	r.tools.tools[name] = fn
+}
+
+func (t *toolRunner) onArgumentsDone(event *events.Event) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.tools) == 0 {
+		return nil
+	}
+	call := &toolCall{outputIndex: event.OutputIndex, callID: event.CallID, done: make(chan struct{})}
+	t.calls[event.ResponseID] = append(t.calls[event.ResponseID], call)
+	fn := t.tools[event.Name]
+	go func() {
+		defer close(call.done)
+		call.output = t.invoke(event.Name, fn, event.Arguments)
+	}()
+	return nil
+}
+
+// invoke runs fn and returns the output for the model, errors included. A
+// panic of fn and fn running past the tool timeout are reported as errors.
+func (t *toolRunner) invoke(name string, fn ToolFunc, arguments string) string {
+	result, err := func() (any, error) {
+		if fn == nil {
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		}
+		if arguments == "" {
+			arguments = "{}"
+		}
+		if !json.Valid([]byte(arguments)) {
+			return nil, fmt.Errorf("arguments are not valid JSON: %s", arguments)
+		}
+		t.client.lock.RLock()
+		ctx := t.client.ctx
+		t.client.lock.RUnlock()
+		ctx, cancel := context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+		return t.call(ctx, name, fn, json.RawMessage(arguments))
+	}()
+	if err != nil {
+		t.client.log().Warn("Tool failed", "tool", name, "err", err)
+		output, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return string(output)
+	}
+	if s, ok := result.(string); ok {
+		return s
+	}
+	output, err := json.Marshal(result)
+	if err != nil {
+		output, _ = json.Marshal(map[string]string{"error": fmt.Sprintf("encode result failed: %v", err)})
+	}
+	return string(output)
+}
+
+// call runs fn on its own goroutine, so that a tool ignoring ctx does not
+// hold up the reply past the timeout.
+func (t *toolRunner) call(ctx context.Context, name string, fn ToolFunc, arguments json.RawMessage) (any, error) {
+	type toolResult struct {
+		value any
+		err   error
+	}
+	done := make(chan toolResult, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				t.client.log().Error("Tool panicked", "tool", name, "panic", p, "stack", string(debug.Stack()))
+				done <- toolResult{err: fmt.Errorf("tool panicked: %v", p)}
+			}
+		}()
+		value, err := fn(ctx, arguments)
+		done <- toolResult{value, err}
+	}()
+	select {
+	case result := <-done:
+		if result.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errToolTimedOut
+		}
+		return result.value, result.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errToolTimedOut
+		}
+		return nil, context.Cause(ctx)
+	}
+}
+
+func (t *toolRunner) onResponseDone(event *events.Event) error {
+	if event.Response == nil {
+		return nil
+	}
+	t.lock.Lock()
+	calls := t.calls[event.Response.ID]
+	delete(t.calls, event.Response.ID)
+	t.lock.Unlock()
+	if len(calls) == 0 {
+		return nil
+	}
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].outputIndex < calls[j].outputIndex })
+	createResponse := event.Response.Status != events.ResponseStatusCancelled
+	go t.reply(calls, createResponse)
+	return nil
+}
+
+// reply waits for the calls and sends their outputs, then asks for a response.
+func (t *toolRunner) reply(calls []*toolCall, createResponse bool) {
+	for _, call := range calls {
+		<-call.done
+		output := call.output
+		err := t.client.Send(&events.Event{
+			Type: events.RealtimeClientEventConversationItemCreate,
+			Item: &events.Item{
+				Type:   events.ItemTypeFunctionCallOutput,
+				CallId: call.callID,
+				Output: &output,
+			},
+		})
+		if err != nil {
+			t.client.log().Warn("Send function call output failed", "call_id", call.callID, "err", err)
+			return
+		}
+	}
+	if !createResponse {
+		return
+	}
+	if err := t.client.Send(&events.Event{Type: events.RealtimeClientEventResponseCreate}); err != nil {
+		t.client.log().Warn("Send response.create failed", "err", err)
+	}
+}
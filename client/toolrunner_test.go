@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestToolRunner(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.QueueResponse([]*events.Event{
+		{Type: events.RealtimeServerEventResponseCreated, Response: &events.Response{ID: "resp_1"}},
+		{Type: events.RealtimeServerEventResponseFunctionCallArgumentsDone, ResponseID: "resp_1", OutputIndex: 1,
+			CallID: "call_2", Name: "Fail", Arguments: `{}`},
+		{Type: events.RealtimeServerEventResponseFunctionCallArgumentsDone, ResponseID: "resp_1", OutputIndex: 0,
+			CallID: "call_1", Name: "SearchWeather", Arguments: `{"localtion":"北京"}`},
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: "resp_1", Status: events.ResponseStatusCompleted}},
+	})
+
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	type weatherArgs struct {
+		Location string `json:"localtion"`
+	}
+	c.RegisterTool("SearchWeather", TypedTool(func(ctx context.Context, args weatherArgs) (any, error) {
+		return map[string]string{"city": args.Location, "weather": "晴"}, nil
+	}))
+	c.RegisterTool("Fail", func(ctx context.Context, arguments json.RawMessage) (any, error) {
+		return nil, errors.New("service unavailable")
+	})
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Send(&events.Event{Type: events.RealtimeClientEventResponseCreate}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.ReceivedOfType(events.RealtimeClientEventResponseCreate)) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("follow-up response.create not received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	outputs := srv.ReceivedOfType(events.RealtimeClientEventConversationItemCreate)
+	if len(outputs) != 2 {
+		t.Fatalf("received %d function_call_output items, want 2", len(outputs))
+	}
+	want := []struct{ callID, output string }{
+		{"call_1", `{"city":"北京","weather":"晴"}`},
+		{"call_2", `{"error":"service unavailable"}`},
+	}
+	for i, event := range outputs {
+		item := event.Item
+		if item.Type != events.ItemTypeFunctionCallOutput || item.CallId != want[i].callID || item.Output == nil || *item.Output != want[i].output {
+			t.Errorf("output %d = %+v, want %+v", i, item, want[i])
+		}
+	}
+}
+
+func TestToolRunnerHangAndPanic(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	srv.QueueResponse([]*events.Event{
+		{Type: events.RealtimeServerEventResponseCreated, Response: &events.Response{ID: "resp_1"}},
+		{Type: events.RealtimeServerEventResponseFunctionCallArgumentsDone, ResponseID: "resp_1", OutputIndex: 0,
+			CallID: "call_1", Name: "Hang"},
+		{Type: events.RealtimeServerEventResponseFunctionCallArgumentsDone, ResponseID: "resp_1", OutputIndex: 1,
+			CallID: "call_2", Name: "Panic"},
+		{Type: events.RealtimeServerEventResponseDone, Response: &events.Response{ID: "resp_1", Status: events.ResponseStatusCompleted}},
+	})
+
+	c := NewRealtimeClient(srv.URL, "test", nil, WithToolTimeout(50*time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+	c.RegisterTool("Hang", func(context.Context, json.RawMessage) (any, error) {
+		// Ignores ctx.
+		<-release
+		return "too late", nil
+	})
+	c.RegisterTool("Panic", func(context.Context, json.RawMessage) (any, error) {
+		panic("boom")
+	})
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Send(&events.Event{Type: events.RealtimeClientEventResponseCreate}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.ReceivedOfType(events.RealtimeClientEventResponseCreate)) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("follow-up response.create not received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	outputs := srv.ReceivedOfType(events.RealtimeClientEventConversationItemCreate)
+	want := []string{`{"error":"tool timed out"}`, `{"error":"tool panicked: boom"}`}
+	if len(outputs) != len(want) {
+		t.Fatalf("received %d function_call_output items, want %d", len(outputs), len(want))
+	}
+	for i, event := range outputs {
+		if output := event.Item.Output; output == nil || *output != want[i] {
+			t.Errorf("output %d = %+v, want %s", i, event.Item, want[i])
+		}
+	}
+}
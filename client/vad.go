@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+const vadWindow = 10 * time.Millisecond
+
+// VAD is a voice activity detector for sessions without server turn
+// detection. It measures the energy of the outgoing 16 bit mono PCM in 10ms
+// windows and reports speech start and stop; with AutoCommit it commits the
+// input audio buffer when speech stops. The parameters are named after
+// events.TurnDetection. Times are milliseconds of audio since the VAD was
+// created. The hangover is measured in audio, so a producer that stops
+// sending audio must call Flush to end the speech.
+type VAD struct {
+	// Threshold is the RMS level relative to full scale, between 0 and 1,
+	// above which a window is speech.
+	Threshold float64
+	// PrefixPaddingMs of audio before the first loud window count as speech.
+	PrefixPaddingMs int
+	// SilenceDurationMs is the hangover: the silence after which speech is
+	// stopped.
+	SilenceDurationMs int
+	// AutoCommit sends input_audio_buffer.commit when speech stops,
+	// CreateResponse then sends response.create.
+	AutoCommit     bool
+	CreateResponse bool
+
+	OnSpeechStarted func(audioStartMS int64)
+	OnSpeechStopped func(audioEndMS int64)
+
+	client RealtimeClient
+
+	lock     sync.Mutex
+	samples  int64   // samples processed
+	window   []int16 // samples of the incomplete window
+	speaking bool
+	lastLoud int64 // end of the last loud window, in samples
+}
+
+// NewVAD returns a detector with a threshold of 0.02, 300ms prefix padding
+// and 500ms silence duration. c is used by AutoCommit and may be nil otherwise.
+func NewVAD(c RealtimeClient) *VAD {
+	return &VAD{Threshold: 0.02, PrefixPaddingMs: 300, SilenceDurationMs: 500, client: c}
+}
+
+// Observe processes the audio of an input_audio_buffer.append event, other
+// events are ignored.
+func (v *VAD) Observe(ctx context.Context, event *events.Event) error {
+	if event.Type != events.RealtimeClientEventInputAudioBufferAppend {
+		return nil
+	}
+	pcm, err := base64.StdEncoding.DecodeString(event.Audio)
+	if err != nil {
+		return fmt.Errorf("decode audio failed: %v", err)
+	}
+	return v.Process(ctx, pcm)
+}
+
+// Process analyzes PCM in InputPCMFormat.
+func (v *VAD) Process(ctx context.Context, pcm []byte) error {
+	windowSize := InputPCMFormat.Bytes(vadWindow) / 2
+	v.lock.Lock()
+	var transitions []vadTransition
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v.window = append(v.window, int16(binary.LittleEndian.Uint16(pcm[i:])))
+		if len(v.window) < windowSize {
+			continue
+		}
+		end := v.samples + int64(len(v.window))
+		loud := rms(v.window) >= v.Threshold
+		switch {
+		case loud && !v.speaking:
+			v.speaking = true
+			start := max(v.samples-v.msToSamples(v.PrefixPaddingMs), 0)
+			transitions = append(transitions, vadTransition{speaking: true, ms: v.samplesToMS(start)})
+		case !loud && v.speaking && end-v.lastLoud >= v.msToSamples(v.SilenceDurationMs):
+			v.speaking = false
+			transitions = append(transitions, vadTransition{speaking: false, ms: v.samplesToMS(v.lastLoud)})
+		}
+		if loud {
+			v.lastLoud = end
+		}
+		v.samples, v.window = end, v.window[:0]
+	}
+	v.lock.Unlock()
+	return v.notify(ctx, transitions)
+}
+
+// Flush ends the audio: speech in progress is stopped at its last loud window
+// and, with AutoCommit, committed. The VAD may process further audio.
+func (v *VAD) Flush(ctx context.Context) error {
+	v.lock.Lock()
+	var transitions []vadTransition
+	if v.speaking {
+		v.speaking = false
+		transitions = append(transitions, vadTransition{speaking: false, ms: v.samplesToMS(v.lastLoud)})
+	}
+	v.samples += int64(len(v.window))
+	v.window = v.window[:0]
+	v.lock.Unlock()
+	return v.notify(ctx, transitions)
+}
+
+// notify runs the callbacks and sends of transitions, without the lock.
+func (v *VAD) notify(ctx context.Context, transitions []vadTransition) error {
+	for _, t := range transitions {
+		if t.speaking {
+			if v.OnSpeechStarted != nil {
+				v.OnSpeechStarted(t.ms)
+			}
+			continue
+		}
+		if v.OnSpeechStopped != nil {
+			v.OnSpeechStopped(t.ms)
+		}
+		if err := v.commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type vadTransition struct {
+	speaking bool
+	ms       int64
+}
+
+func (v *VAD) commit(ctx context.Context) error {
+	if !v.AutoCommit {
+		return nil
+	}
+	if err := v.client.SendContext(ctx, &events.Event{Type: events.RealtimeClientEventInputAudioBufferCommit}); err != nil {
+		return err
+	}
+	if v.CreateResponse {
+		return v.client.SendContext(ctx, &events.Event{Type: events.RealtimeClientEventResponseCreate})
+	}
+	return nil
+}
+
+func (v *VAD) msToSamples(ms int) int64 {
+	return int64(ms) * int64(InputPCMFormat.SampleRate) / 1000
+}
+
+func (v *VAD) samplesToMS(n int64) int64 {
+	return n * 1000 / int64(InputPCMFormat.SampleRate)
+}
+
+// rms returns the root mean square of samples relative to full scale.
+func rms(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		f := float64(s) / 32768
+		sum += f * f
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/t8y2/glm4.5v-realtime-video/golang/client/realtimetest"
+	"github.com/t8y2/glm4.5v-realtime-video/golang/events"
+)
+
+func TestVADAutoCommit(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	vad := NewVAD(c)
+	vad.AutoCommit, vad.CreateResponse = true, true
+	var started, stopped []int64
+	vad.OnSpeechStarted = func(ms int64) { started = append(started, ms) }
+	vad.OnSpeechStopped = func(ms int64) { stopped = append(stopped, ms) }
+
+	silence := make([]byte, InputPCMFormat.Bytes(time.Second))
+	var pcm []byte
+	pcm = append(pcm, silence...)
+	pcm = append(pcm, sine(InputPCMFormat, time.Second)...)
+	pcm = append(pcm, silence...)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Feed the audio in uneven chunks, windows span the chunk boundaries.
+	for len(pcm) > 0 {
+		n := min(len(pcm), 1234)
+		if err := vad.Process(ctx, pcm[:n]); err != nil {
+			t.Fatal(err)
+		}
+		pcm = pcm[n:]
+	}
+
+	if len(started) != 1 || started[0] != 1000-300 {
+		t.Errorf("speech started at %v, want 700ms with the prefix padding", started)
+	}
+	if len(stopped) != 1 || stopped[0] != 2000 {
+		t.Errorf("speech stopped at %v, want 2000ms", stopped)
+	}
+	if _, err := srv.WaitFor(ctx, events.RealtimeClientEventResponseCreate); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.ReceivedOfType(events.RealtimeClientEventInputAudioBufferCommit)); n != 1 {
+		t.Errorf("got %d commits, want 1", n)
+	}
+}
+
+func TestVADFlushEndsSpeech(t *testing.T) {
+	srv := realtimetest.NewServer()
+	defer srv.Close()
+	c := NewRealtimeClient(srv.URL, "test", nil)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	vad := NewVAD(c)
+	vad.AutoCommit = true
+	var stopped []int64
+	vad.OnSpeechStopped = func(ms int64) { stopped = append(stopped, ms) }
+	streamer := NewAudioStreamer(c)
+	streamer.Unpaced, streamer.VAD = true, vad
+
+	// The audio ends mid-speech, the hangover never passes.
+	var pcm []byte
+	pcm = append(pcm, make([]byte, InputPCMFormat.Bytes(500*time.Millisecond))...)
+	pcm = append(pcm, sine(InputPCMFormat, time.Second)...)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := streamer.StreamPCM(ctx, bytes.NewReader(pcm), InputPCMFormat); err != nil {
+		t.Fatal(err)
+	}
+	if len(stopped) != 1 || stopped[0] != 1500 {
+		t.Errorf("speech stopped at %v, want 1500ms when the audio ended", stopped)
+	}
+	if _, err := srv.WaitFor(ctx, events.RealtimeClientEventInputAudioBufferCommit); err != nil {
+		t.Fatal(err)
+	}
+	// Flushing again does nothing.
+	if err := vad.Flush(ctx); err != nil || len(stopped) != 1 {
+		t.Errorf("second Flush() = %v with %d stops", err, len(stopped))
+	}
+}
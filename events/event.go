@@ -94,6 +94,7 @@ type EventError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Param   string `json:"param,omitempty"`
+	EventID string `json:"event_id,omitempty"` // 引发错误的客户端事件 ID
 }
 
 type Conversation struct {
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// LogPayloadLimit 日志中 base64 音视频负载最多保留的字符数
+const LogPayloadLimit = 32
+
+// LogValue 实现 slog.LogValuer，记录事件类型、事件 ID 和截断了音视频负载的 JSON
+func (e *Event) LogValue() slog.Value {
+	if e == nil {
+		return slog.StringValue("<nil>")
+	}
+	attrs := []slog.Attr{slog.String("type", string(e.Type))}
+	if e.EventID != "" {
+		attrs = append(attrs, slog.String("event_id", e.EventID))
+	}
+
+	c := *e
+	c.Audio = TruncatePayload(c.Audio)
+	if c.Type == RealtimeServerEventResponseAudioDelta {
+		c.Delta = TruncatePayload(c.Delta)
+	}
+	if len(c.VideoFrame) > 0 {
+		// []byte 无法保存截断标记，只记录帧大小
+		attrs = append(attrs, slog.Int("video_frame_bytes", len(c.VideoFrame)))
+		c.VideoFrame = nil
+	}
+	if c.Part != nil && c.Part.Audio != "" {
+		part := *c.Part
+		part.Audio = TruncatePayload(part.Audio)
+		c.Part = &part
+	}
+	attrs = append(attrs, slog.String("json", c.ToJson()))
+	return slog.GroupValue(attrs...)
+}
+
+// TruncatePayload 将超过 LogPayloadLimit 的 base64 负载截断并标注原长度
+func TruncatePayload(s string) string {
+	if len(s) <= LogPayloadLimit {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d chars)", s[:LogPayloadLimit], len(s))
+}
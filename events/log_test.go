@@ -0,0 +1,26 @@
+package events
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestEventLogValueTruncatesPayloads(t *testing.T) {
+	audio := strings.Repeat("A", 4096)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("sent", "event", &Event{EventID: "evt_1", Type: RealtimeClientEventInputAudioBufferAppend, Audio: audio})
+	logger.Debug("sent", "event", &Event{Type: RealtimeClientVideoAppend, VideoFrame: make([]byte, 2048)})
+
+	out := buf.String()
+	if strings.Contains(out, audio) {
+		t.Fatalf("audio payload not truncated: %d bytes logged", len(out))
+	}
+	for _, want := range []string{"event.event_id=evt_1", "(4096 chars)", "event.video_frame_bytes=2048"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output misses %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewFunctionTool 根据参数结构体 args 生成 function 类型的 Tool，参数 Schema 由 SchemaOf 生成
+func NewFunctionTool(name, description string, args any) (Tool, error) {
+	schema, err := SchemaOf(args)
+	if err != nil {
+		return Tool{}, fmt.Errorf("tool %s: %v", name, err)
+	}
+	return Tool{Type: "function", Name: name, Description: description, Parameters: *schema}, nil
+}
+
+// SchemaOf 通过反射生成结构体 v 的 JSON Schema，字段名取自 json tag，并支持以下 tag:
+//   - description:"城市名称"  字段描述
+//   - enum:"sunny,rainy"     可选值，按字段类型解析
+//   - required:"true|false"  是否必填，默认非指针且没有 omitempty 的字段为必填
+//
+// 结构体生成的 object 不允许额外属性。
+func SchemaOf(v any) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema requires a struct, got %v", t)
+	}
+	schema, err := schemaOfType(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = SchemaDraft07
+	return schema, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOfType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte 按 base64 字符串编码
+			return &Schema{Type: "string"}, nil
+		}
+		items, err := schemaOfType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key of %v must be a string", t)
+		}
+		values, err := schemaOfType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return schemaOfStruct(t, visiting)
+	}
+	return nil, fmt.Errorf("unsupported type %v", t)
+}
+
+func schemaOfStruct(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("recursive type %v", t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	schema := &Schema{Type: "object", Properties: map[string]Schema{}, AdditionalProperties: false}
+	if err := addFields(schema, t, visiting); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func addFields(schema *Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		// 匿名结构体字段展开到外层
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addFields(schema, ft, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := schemaOfType(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.Name, err)
+		}
+		prop.Description = field.Tag.Get("description")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			// 数组字段的可选值约束其元素
+			target := prop
+			if prop.Type == "array" && prop.Items != nil {
+				target = prop.Items
+			}
+			if target.Enum, err = parseEnum(enum, field.Type); err != nil {
+				return fmt.Errorf("field %s: %v", field.Name, err)
+			}
+		}
+		schema.Properties[name] = *prop
+
+		required := field.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty")
+		if tag := field.Tag.Get("required"); tag != "" {
+			required = tag == "true"
+		}
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
+
+func parseEnum(tag string, t reflect.Type) ([]any, error) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	var values []any
+	for _, s := range strings.Split(tag, ",") {
+		s = strings.TrimSpace(s)
+		var value any
+		var err error
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value, err = strconv.ParseInt(s, 10, 64)
+		case reflect.Float32, reflect.Float64:
+			value, err = strconv.ParseFloat(s, 64)
+		case reflect.Bool:
+			value, err = strconv.ParseBool(s)
+		default:
+			value = s
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid enum value %q: %v", s, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
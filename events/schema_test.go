@@ -0,0 +1,51 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewFunctionTool(t *testing.T) {
+	type searchWeatherArgs struct {
+		Location string `json:"localtion" description:"要查询天气的城市"`
+		Date     string `json:"date,omitempty" description:"要查询天气的日期"`
+	}
+	tool, err := NewFunctionTool("SearchWeather", "查询指定城市的天气", searchWeatherArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(tool)
+	// 与 samples/files/Video.ClientVad.Input 中注释掉的 SearchWeather 工具一致
+	want := `{"type":"function","name":"SearchWeather","description":"查询指定城市的天气","parameters":{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{"date":{"type":"string","description":"要查询天气的日期"},"localtion":{"type":"string","description":"要查询天气的城市"}},"required":["localtion"],"additionalProperties":false}}`
+	if string(got) != want {
+		t.Errorf("tool json\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSchemaOfNested(t *testing.T) {
+	type place struct {
+		City string `json:"city"`
+	}
+	type args struct {
+		Units  string   `json:"units" enum:"metric,imperial"`
+		Days   []int    `json:"days" enum:"1,3,7"`
+		Places []place  `json:"places"`
+		Limit  *float64 `json:"limit"`
+	}
+	schema, err := SchemaOf(&args{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schema.Properties["units"].Enum; len(got) != 2 || got[0] != "metric" {
+		t.Errorf("units enum = %v", got)
+	}
+	if got := schema.Properties["days"].Items.Enum; len(got) != 3 || got[2] != int64(7) {
+		t.Errorf("days enum = %v", got)
+	}
+	if got := schema.Properties["places"].Items.Properties["city"].Type; got != "string" {
+		t.Errorf("places.items.city type = %q", got)
+	}
+	if len(schema.Required) != 3 {
+		t.Errorf("required = %v, want pointer field optional", schema.Required)
+	}
+}
@@ -7,13 +7,33 @@ type Tool struct {
 	Parameters  ToolParameters `json:"parameters"`
 }
 
-type ToolParameters struct {
-	Type       string                  `json:"type"`
-	Properties map[string]ToolProperty `json:"properties"`
-	Required   []string                `json:"required"`
-}
+// ToolParameters 和 ToolProperty 保留旧名称，均为 JSON Schema
+type ToolParameters = Schema
+type ToolProperty = Schema
+
+// SchemaDraft07 是工具参数 $schema 字段的取值
+const SchemaDraft07 = "http://json-schema.org/draft-07/schema#"
 
-type ToolProperty struct {
-	Type        string `json:"type,omitempty"`
-	Description string `json:"description,omitempty"`
+// Schema 是 JSON Schema (draft-07) 中工具参数常用的子集
+type Schema struct {
+	Schema      string            `json:"$schema,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Properties  map[string]Schema `json:"properties,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	// AdditionalProperties 为 bool 或 *Schema
+	AdditionalProperties any      `json:"additionalProperties,omitempty"`
+	Items                *Schema  `json:"items,omitempty"`
+	Enum                 []any    `json:"enum,omitempty"`
+	Format               string   `json:"format,omitempty"`
+	Pattern              string   `json:"pattern,omitempty"`
+	Minimum              *float64 `json:"minimum,omitempty"`
+	Maximum              *float64 `json:"maximum,omitempty"`
+	MinLength            *int     `json:"minLength,omitempty"`
+	MaxLength            *int     `json:"maxLength,omitempty"`
+	MinItems             *int     `json:"minItems,omitempty"`
+	MaxItems             *int     `json:"maxItems,omitempty"`
+	Default              any      `json:"default,omitempty"`
+	AnyOf                []Schema `json:"anyOf,omitempty"`
 }
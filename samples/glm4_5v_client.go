@@ -7,13 +7,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+var packageLogger atomic.Pointer[slog.Logger]
+
+// SetLogger 设置 GLM-4.5v 辅助函数使用的日志，nil 表示使用 slog.Default()
+func SetLogger(l *slog.Logger) {
+	packageLogger.Store(l)
+}
+
+func logger() *slog.Logger {
+	if l := packageLogger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
 // GLM45VRequest GLM-4.5v API 请求结构
 type GLM45VRequest struct {
 	Model    string          `json:"model"`
@@ -82,7 +97,7 @@ func ProcessVideoWithGLM45V(inputFilePath string, prompt string, outputFilePath
 		return nil, fmt.Errorf("no video frames found in input file")
 	}
 
-	log.Printf("  Extracted Frames:   %d\n", len(frames))
+	logger().Info("extracted video frames", "input", inputFilePath, "frames", len(frames))
 
 	// 调用 GLM-4.5v API
 	response, err := CallGLM45V(apiKey, frames, prompt)
@@ -93,7 +108,7 @@ func ProcessVideoWithGLM45V(inputFilePath string, prompt string, outputFilePath
 	// 写入输出文件(如果指定)
 	if outputFilePath != "" {
 		if err := WriteResponseToFile(response, outputFilePath); err != nil {
-			log.Printf("Warning: failed to write output file: %v\n", err)
+			logger().Warn("failed to write output file", "output", outputFilePath, "err", err)
 		}
 	}
 
@@ -135,7 +150,7 @@ func ExtractVideoFramesFromRealtimeFile(inputFilePath string) ([][]byte, error)
 				// 解码base64
 				frameData, err := base64.StdEncoding.DecodeString(videoFrameStr)
 				if err != nil {
-					log.Printf("Warning: decode video frame failed: %v\n", err)
+					logger().Warn("decode video frame failed", "event_id", event["event_id"], "err", err)
 					continue
 				}
 				frames = append(frames, frameData)
@@ -262,6 +277,6 @@ func WriteResponseToFile(response *GLM45VResponse, outputPath string) error {
 		return fmt.Errorf("write to file failed: %v", err)
 	}
 
-	log.Printf("  Output File:      %s\n", outputPath)
+	logger().Info("response written", "output", outputPath)
 	return nil
 }
@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// FrameExtractor 从 H.264 Annex-B 视频中抽取 JPEG 图片帧，视频需自带 SPS/PPS
+type FrameExtractor interface {
+	ExtractFrames(ctx context.Context, h264 []byte) ([][]byte, error)
+}
+
+// FFmpegExtractor 调用 ffmpeg 抽帧，视频从 stdin 输入，图片经 image2pipe 从 stdout 输出，不写临时文件
+type FFmpegExtractor struct {
+	// Path 为 ffmpeg 可执行文件，默认 "ffmpeg"
+	Path string
+	// FPS 为每秒抽取的帧数，默认 2
+	FPS float64
+	// Quality 为 JPEG 质量 (-q:v)，2 到 31，越小质量越高，默认 2
+	Quality int
+	// Width、Height 非 0 时缩放图片，-1 表示按比例计算
+	Width, Height int
+}
+
+// NewFFmpegExtractor 返回每秒 2 帧、最高质量的 FFmpegExtractor
+func NewFFmpegExtractor() *FFmpegExtractor {
+	return &FFmpegExtractor{Path: "ffmpeg", FPS: 2, Quality: 2}
+}
+
+func (e *FFmpegExtractor) args() []string {
+	fps, quality := e.FPS, e.Quality
+	if fps <= 0 {
+		fps = 2
+	}
+	if quality <= 0 {
+		quality = 2
+	}
+	filter := "fps=" + strconv.FormatFloat(fps, 'f', -1, 64)
+	if e.Width != 0 || e.Height != 0 {
+		width, height := e.Width, e.Height
+		if width == 0 {
+			width = -1
+		}
+		if height == 0 {
+			height = -1
+		}
+		filter += fmt.Sprintf(",scale=%d:%d", width, height)
+	}
+	return []string{
+		"-hide_banner", "-loglevel", "error",
+		"-f", "h264", "-i", "pipe:0",
+		"-vf", filter,
+		"-q:v", strconv.Itoa(quality),
+		"-f", "image2pipe", "-c:v", "mjpeg",
+		"pipe:1",
+	}
+}
+
+// ExtractFrames 实现 FrameExtractor，ctx 结束时终止 ffmpeg
+func (e *FFmpegExtractor) ExtractFrames(ctx context.Context, h264 []byte) ([][]byte, error) {
+	path := e.Path
+	if path == "" {
+		path = "ffmpeg"
+	}
+	cmd := exec.CommandContext(ctx, path, e.args()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(h264)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	logger().Debug("running ffmpeg", "args", cmd.Args)
+	err := cmd.Run()
+	logger().Debug("ffmpeg finished", "output", stderr.String())
+	if ctxErr := context.Cause(ctx); ctxErr != nil {
+		return nil, fmt.Errorf("ffmpeg execution failed: %w", ctxErr)
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("ffmpeg execution failed: %v, output: %s", err, tail(stderr.Bytes(), 512))
+		}
+		return nil, fmt.Errorf("ffmpeg execution failed: %v", err)
+	}
+
+	images, err := SplitJPEGs(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	logger().Debug("extracted frames", "count", len(images))
+	return images, nil
+}
+
+// SplitJPEGs 将 image2pipe 输出的连续 JPEG 数据切分为单张图片
+func SplitJPEGs(data []byte) ([][]byte, error) {
+	var images [][]byte
+	for len(data) > 0 {
+		if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+			return nil, fmt.Errorf("invalid JPEG data at image %d", len(images))
+		}
+		size, err := jpegSize(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JPEG image %d: %v", len(images), err)
+		}
+		images = append(images, data[:size])
+		data = data[size:]
+	}
+	return images, nil
+}
+
+// jpegSize 按标记段解析以 SOI 开头的 JPEG，返回到 EOI 为止的字节数
+func jpegSize(data []byte) (int, error) {
+	pos := 2
+	for pos+1 < len(data) {
+		if data[pos] != 0xff {
+			return 0, fmt.Errorf("missing marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xff: // 填充字节
+			pos++
+			continue
+		case marker == 0xd9: // EOI
+			return pos + 2, nil
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd7: // 无长度的标记
+			pos += 2
+			continue
+		}
+		if pos+3 >= len(data) {
+			break
+		}
+		pos += 2 + (int(data[pos+2])<<8 | int(data[pos+3]))
+		if marker != 0xda { // SOS 之后是熵编码数据
+			continue
+		}
+		// 熵编码数据中的 0xff 后跟 0x00 或 RST 标记，其余标记表示数据结束
+		for pos+1 < len(data) && (data[pos] != 0xff || data[pos+1] == 0x00 || data[pos+1] >= 0xd0 && data[pos+1] <= 0xd7) {
+			pos++
+		}
+	}
+	return 0, fmt.Errorf("missing EOI marker")
+}
+
+// FakeExtractor 是不调用 ffmpeg 的 FrameExtractor，用于测试:
+// 设置了 Frames 时返回 Frames，否则为视频中每个含 slice 的访问单元返回一张占位图片
+type FakeExtractor struct {
+	Frames [][]byte
+	Err    error
+
+	lock  sync.Mutex
+	calls [][]byte
+}
+
+// ExtractFrames 实现 FrameExtractor
+func (e *FakeExtractor) ExtractFrames(ctx context.Context, h264 []byte) ([][]byte, error) {
+	e.lock.Lock()
+	e.calls = append(e.calls, h264)
+	e.lock.Unlock()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	if e.Frames != nil {
+		return e.Frames, nil
+	}
+	var frames [][]byte
+	for _, au := range SplitAccessUnits(ParseNALUnits(h264)) {
+		for _, nal := range au.NALs {
+			if nal.IsVCL() {
+				frames = append(frames, []byte(fmt.Sprintf("frame %d", len(frames))))
+				break
+			}
+		}
+	}
+	return frames, nil
+}
+
+// Calls 返回每次调用收到的视频
+func (e *FakeExtractor) Calls() [][]byte {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([][]byte(nil), e.calls...)
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitJPEGs(t *testing.T) {
+	// APP0 段内的 ff d9 与熵编码数据中的 ff 00、ff d0 都不是结束标记
+	image := []byte{
+		0xff, 0xd8,
+		0xff, 0xe0, 0x00, 0x06, 0xff, 0xd9, 0x00, 0x00,
+		0xff, 0xda, 0x00, 0x03, 0x01,
+		0x12, 0xff, 0x00, 0x34, 0xff, 0xd0, 0x56,
+		0xff, 0xd9,
+	}
+	images, err := SplitJPEGs(append(append([]byte(nil), image...), image...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 || !bytes.Equal(images[0], image) || !bytes.Equal(images[1], image) {
+		t.Errorf("SplitJPEGs returned %d images: %x", len(images), images)
+	}
+	if _, err := SplitJPEGs(image[:len(image)-2]); err == nil {
+		t.Error("image without EOI accepted")
+	}
+}
+
+func TestFFmpegExtractorArgs(t *testing.T) {
+	e := &FFmpegExtractor{FPS: 0.5, Quality: 5, Width: 640}
+	args := strings.Join(e.args(), " ")
+	for _, want := range []string{"-vf fps=0.5,scale=640:-1", "-q:v 5", "-f image2pipe"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q miss %q", args, want)
+		}
+	}
+	if args := NewFFmpegExtractor().args(); !slices.Contains(args, "fps=2") {
+		t.Errorf("default args %v, want fps=2", args)
+	}
+}
+
+func TestFakeExtractor(t *testing.T) {
+	var e FrameExtractor = &FakeExtractor{}
+	video := bytes.Join([][]byte{nil, {0x67, 0x42}, {0x68, 0xce}, {0x65, 0x88}, {0x41, 0x9a}, {0x41, 0x9a}}, []byte{0, 0, 0, 1})
+	frames, err := e.ExtractFrames(context.Background(), video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Errorf("%d frames, want one per access unit", len(frames))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.ExtractFrames(ctx, video); !errors.Is(err, context.Canceled) {
+		t.Errorf("ExtractFrames with canceled context = %v", err)
+	}
+}
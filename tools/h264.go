@@ -0,0 +1,354 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// H.264 NAL 单元类型
+const (
+	NALTypeNonIDR = 1
+	NALTypeIDR    = 5
+	NALTypeSEI    = 6
+	NALTypeSPS    = 7
+	NALTypePPS    = 8
+	NALTypeAUD    = 9
+)
+
+var nalTypeNames = map[int]string{
+	NALTypeNonIDR: "non-IDR",
+	NALTypeIDR:    "IDR",
+	NALTypeSEI:    "SEI",
+	NALTypeSPS:    "SPS",
+	NALTypePPS:    "PPS",
+	NALTypeAUD:    "AUD",
+}
+
+var startCode = []byte{0x00, 0x00, 0x01}
+
+// SplitAnnexB 按起始码 (00 00 01 或 00 00 00 01) 切分 Annex-B 字节流，返回不含起始码的 NAL 单元。
+// 第一个起始码之前的数据被忽略，NAL 单元末尾的补零会被去掉。
+func SplitAnnexB(data []byte) [][]byte {
+	var nals [][]byte
+	start := bytes.Index(data, startCode)
+	for start >= 0 {
+		start += len(startCode)
+		next := bytes.Index(data[start:], startCode)
+		end := len(data)
+		if next >= 0 {
+			end = start + next
+		}
+		if nal := bytes.TrimRight(data[start:end], "\x00"); len(nal) > 0 {
+			nals = append(nals, nal)
+		}
+		if next < 0 {
+			break
+		}
+		start = end
+	}
+	return nals
+}
+
+// NALType 返回 NAL 单元头中的类型，空单元返回 0
+func NALType(nal []byte) int {
+	if len(nal) == 0 {
+		return 0
+	}
+	return int(nal[0] & 0x1f)
+}
+
+// NALUnit 是 Annex-B 流中的一个 NAL 单元，Data 含单元头、不含起始码
+type NALUnit struct {
+	Type   int
+	RefIDC int
+	Data   []byte
+}
+
+// ParseNALUnits 切分并分类 Annex-B 字节流
+func ParseNALUnits(data []byte) []NALUnit {
+	raw := SplitAnnexB(data)
+	nals := make([]NALUnit, 0, len(raw))
+	for _, nal := range raw {
+		nals = append(nals, NALUnit{Type: NALType(nal), RefIDC: int(nal[0]>>5) & 0x03, Data: nal})
+	}
+	return nals
+}
+
+func (n NALUnit) String() string {
+	if name, ok := nalTypeNames[n.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("NAL(%d)", n.Type)
+}
+
+// IsVCL 判断是否为编码图像的 slice
+func (n NALUnit) IsVCL() bool {
+	return n.Type >= NALTypeNonIDR && n.Type <= NALTypeIDR
+}
+
+// AccessUnit 是组成一帧图像的 NAL 单元
+type AccessUnit struct {
+	NALs []NALUnit
+}
+
+// IsKeyframe 判断是否包含 IDR slice
+func (a AccessUnit) IsKeyframe() bool {
+	for _, nal := range a.NALs {
+		if nal.Type == NALTypeIDR {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitAccessUnits 按 H.264 7.4.1.2.3 将 NAL 单元分组为访问单元:
+// 在已有 slice 之后出现 AUD、SPS、PPS、SEI 或 first_mb_in_slice 为 0 的 slice 时开始新的访问单元
+func SplitAccessUnits(nals []NALUnit) []AccessUnit {
+	var units []AccessUnit
+	var current AccessUnit
+	hasVCL := false
+	for _, nal := range nals {
+		starts := false
+		switch {
+		case nal.IsVCL():
+			starts = hasVCL && firstMBInSlice(nal.Data) == 0
+		case nal.Type >= NALTypeSEI && nal.Type <= NALTypeAUD, nal.Type >= 14 && nal.Type <= 18:
+			starts = hasVCL
+		}
+		if starts {
+			units = append(units, current)
+			current, hasVCL = AccessUnit{}, false
+		}
+		current.NALs = append(current.NALs, nal)
+		hasVCL = hasVCL || nal.IsVCL()
+	}
+	if len(current.NALs) > 0 {
+		units = append(units, current)
+	}
+	return units
+}
+
+// firstMBInSlice 读取 slice 头的第一个字段，无法解析时返回 -1
+func firstMBInSlice(nal []byte) int {
+	if len(nal) < 2 {
+		return -1
+	}
+	r := &bitReader{data: unescapeRBSP(nal[1:])}
+	v, err := r.ue()
+	if err != nil {
+		return -1
+	}
+	return int(v)
+}
+
+// SPS 是序列参数集中与图像格式相关的字段
+type SPS struct {
+	ProfileIDC      int
+	ConstraintFlags int
+	LevelIDC        int
+	ID              int
+	ChromaFormatIDC int
+	FrameMbsOnly    bool
+	// 裁剪偏移，单位为裁剪单元
+	CropLeft, CropRight, CropTop, CropBottom int
+	// 裁剪后的图像宽高(像素)
+	Width, Height int
+}
+
+// 这些 profile 的 SPS 带有 chroma_format_idc 等扩展字段
+var highProfiles = map[int]bool{100: true, 110: true, 122: true, 244: true, 44: true, 83: true, 86: true, 118: true, 128: true, 138: true, 139: true, 134: true, 135: true}
+
+// ParseSPS 解析 SPS NAL 单元(含单元头)
+func ParseSPS(nal []byte) (*SPS, error) {
+	if NALType(nal) != NALTypeSPS {
+		return nil, fmt.Errorf("not an SPS NAL unit: type %d", NALType(nal))
+	}
+	if len(nal) < 4 {
+		return nil, fmt.Errorf("SPS too short: %d bytes", len(nal))
+	}
+	sps := &SPS{ProfileIDC: int(nal[1]), ConstraintFlags: int(nal[2]), LevelIDC: int(nal[3]), ChromaFormatIDC: 1}
+	r := &bitReader{data: unescapeRBSP(nal[4:])}
+	if err := sps.parse(r); err != nil {
+		return nil, fmt.Errorf("parse SPS failed: %v", err)
+	}
+	return sps, nil
+}
+
+func (sps *SPS) parse(r *bitReader) error {
+	id, err := r.ue()
+	if err != nil {
+		return err
+	}
+	sps.ID = int(id)
+	separateColourPlane := false
+	if highProfiles[sps.ProfileIDC] {
+		chroma, _ := r.ue()
+		sps.ChromaFormatIDC = int(chroma)
+		if chroma == 3 {
+			separateColourPlane = r.flag()
+		}
+		r.ue()        // bit_depth_luma_minus8
+		r.ue()        // bit_depth_chroma_minus8
+		r.flag()      // qpprime_y_zero_transform_bypass_flag
+		if r.flag() { // seq_scaling_matrix_present_flag
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if !r.flag() {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipScalingList(r, size)
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	pocType, _ := r.ue()
+	switch pocType {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.flag() // delta_pic_order_always_zero_flag
+		r.se()   // offset_for_non_ref_pic
+		r.se()   // offset_for_top_to_bottom_field
+		cycle, _ := r.ue()
+		for i := uint32(0); i < cycle && r.err == nil; i++ {
+			r.se()
+		}
+	}
+	r.ue()   // max_num_ref_frames
+	r.flag() // gaps_in_frame_num_value_allowed_flag
+	widthMbs, _ := r.ue()
+	heightMapUnits, _ := r.ue()
+	sps.FrameMbsOnly = r.flag()
+	if !sps.FrameMbsOnly {
+		r.flag() // mb_adaptive_frame_field_flag
+	}
+	r.flag()      // direct_8x8_inference_flag
+	if r.flag() { // frame_cropping_flag
+		for _, crop := range []*int{&sps.CropLeft, &sps.CropRight, &sps.CropTop, &sps.CropBottom} {
+			v, _ := r.ue()
+			*crop = int(v)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	fieldFactor := 1
+	if !sps.FrameMbsOnly {
+		fieldFactor = 2
+	}
+	// 裁剪单元取决于色度采样格式，见 H.264 式 7-19 至 7-22
+	cropUnitX, cropUnitY := 1, fieldFactor
+	if !separateColourPlane && sps.ChromaFormatIDC != 0 {
+		subWidth, subHeight := 2, 2
+		switch sps.ChromaFormatIDC {
+		case 2:
+			subHeight = 1
+		case 3:
+			subWidth, subHeight = 1, 1
+		}
+		cropUnitX, cropUnitY = subWidth, subHeight*fieldFactor
+	}
+	sps.Width = int(widthMbs+1)*16 - (sps.CropLeft+sps.CropRight)*cropUnitX
+	sps.Height = fieldFactor*int(heightMapUnits+1)*16 - (sps.CropTop+sps.CropBottom)*cropUnitY
+	if sps.Width <= 0 || sps.Height <= 0 {
+		return fmt.Errorf("invalid size %dx%d", sps.Width, sps.Height)
+	}
+	return nil
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size && r.err == nil; j++ {
+		if next != 0 {
+			delta, _ := r.se()
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// unescapeRBSP 去掉防竞争字节 (00 00 03 中的 03)
+func unescapeRBSP(data []byte) []byte {
+	if !bytes.Contains(data, []byte{0, 0, 3}) {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	zeros := 0
+	for _, b := range data {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+var errBitsExhausted = errors.New("unexpected end of data")
+
+// bitReader 按位读取 RBSP，出错后的读取均返回零值，错误保存在 err 中
+type bitReader struct {
+	data []byte
+	pos  int // 已读取的位数
+	err  error
+}
+
+func (r *bitReader) bit() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data)*8 {
+		r.err = errBitsExhausted
+		return 0
+	}
+	b := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint32(b)
+}
+
+func (r *bitReader) flag() bool {
+	return r.bit() == 1
+}
+
+// ue 读取无符号指数哥伦布编码
+func (r *bitReader) ue() (uint32, error) {
+	zeros := 0
+	for r.bit() == 0 && r.err == nil {
+		if zeros++; zeros > 31 {
+			r.err = fmt.Errorf("invalid exp-Golomb code")
+		}
+	}
+	var v uint32
+	for i := 0; i < zeros; i++ {
+		v = v<<1 | r.bit()
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 1<<zeros - 1 + v, nil
+}
+
+// se 读取有符号指数哥伦布编码
+func (r *bitReader) se() (int32, error) {
+	v, err := r.ue()
+	if v%2 == 1 {
+		return int32((v + 1) / 2), err
+	}
+	return -int32(v / 2), err
+}
@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"bytes"
+	"testing"
+)
+
+// bitWriter 按位写入，用于构造测试用的 SPS
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) bits(v uint32, count int) {
+	for i := count - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	size := 0
+	for x := v; x > 1; x >>= 1 {
+		size++
+	}
+	w.bits(0, size)
+	w.bits(v, size+1)
+}
+
+// escape 插入防竞争字节
+func escape(rbsp []byte) []byte {
+	var out []byte
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// highSPS 构造 1920x1080 High profile 的 SPS: 1088 行宏块，底部裁剪 4 个单元
+func highSPS() []byte {
+	w := &bitWriter{}
+	w.ue(0)      // seq_parameter_set_id
+	w.ue(1)      // chroma_format_idc
+	w.ue(0)      // bit_depth_luma_minus8
+	w.ue(0)      // bit_depth_chroma_minus8
+	w.bits(0, 1) // qpprime_y_zero_transform_bypass_flag
+	w.bits(0, 1) // seq_scaling_matrix_present_flag
+	w.ue(0)      // log2_max_frame_num_minus4
+	w.ue(0)      // pic_order_cnt_type
+	w.ue(2)      // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(4)      // max_num_ref_frames
+	w.bits(0, 1) // gaps_in_frame_num_value_allowed_flag
+	w.ue(119)    // pic_width_in_mbs_minus1
+	w.ue(67)     // pic_height_in_map_units_minus1
+	w.bits(1, 1) // frame_mbs_only_flag
+	w.bits(1, 1) // direct_8x8_inference_flag
+	w.bits(1, 1) // frame_cropping_flag
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)
+	w.bits(0, 1) // vui_parameters_present_flag
+	w.bits(1, 1) // rbsp_stop_one_bit
+	w.bits(0, (8-w.n%8)%8)
+	return append([]byte{0x67, 100, 0x00, 40}, escape(w.data)...)
+}
+
+func TestParseSPS(t *testing.T) {
+	sps, err := ParseSPS(highSPS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sps.ProfileIDC != 100 || sps.LevelIDC != 40 || sps.ChromaFormatIDC != 1 || !sps.FrameMbsOnly {
+		t.Errorf("unexpected SPS %+v", sps)
+	}
+	if sps.CropBottom != 4 || sps.Width != 1920 || sps.Height != 1080 {
+		t.Errorf("size %dx%d crop bottom %d, want 1920x1080 crop bottom 4", sps.Width, sps.Height, sps.CropBottom)
+	}
+
+	if _, err := ParseSPS([]byte{0x68, 0xce, 0x3c, 0x80}); err == nil {
+		t.Error("PPS parsed as SPS")
+	}
+	if _, err := ParseSPS(highSPS()[:6]); err == nil {
+		t.Error("truncated SPS parsed")
+	}
+}
+
+func TestUnescapeRBSP(t *testing.T) {
+	got := unescapeRBSP([]byte{0x11, 0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x03, 0x00, 0x03})
+	want := []byte{0x11, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03}
+	if !bytes.Equal(got, want) {
+		t.Errorf("unescapeRBSP = % x, want % x", got, want)
+	}
+}
+
+func TestSplitAccessUnits(t *testing.T) {
+	// slice 头以 first_mb_in_slice 开始: 首位为 1 时为 0，0x40 开头为 1
+	stream := bytes.Join([][]byte{
+		{0x09, 0xf0},             // AUD
+		highSPS(),                // SPS
+		{0x68, 0xce, 0x3c, 0x80}, // PPS
+		{0x06, 0x05, 0x01},       // SEI
+		{0x65, 0x88, 0x84},       // IDR，第一个 slice
+		{0x65, 0x40, 0x84},       // IDR，第二个 slice
+		{0x41, 0x9a, 0x02},       // non-IDR
+		{0x41, 0x9a, 0x04},       // non-IDR
+	}, []byte{0x00, 0x00, 0x00, 0x01})
+	nals := ParseNALUnits(append([]byte{0x00, 0x00, 0x00, 0x01}, stream...))
+
+	var names []string
+	for _, nal := range nals {
+		names = append(names, nal.String())
+	}
+	want := []string{"AUD", "SPS", "PPS", "SEI", "IDR", "IDR", "non-IDR", "non-IDR"}
+	if len(names) != len(want) {
+		t.Fatalf("NAL units %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("NAL units %v, want %v", names, want)
+		}
+	}
+	if nals[4].RefIDC != 3 || nals[3].RefIDC != 0 {
+		t.Errorf("nal_ref_idc %d %d", nals[4].RefIDC, nals[3].RefIDC)
+	}
+
+	units := SplitAccessUnits(nals)
+	if len(units) != 3 {
+		t.Fatalf("%d access units, want 3", len(units))
+	}
+	if len(units[0].NALs) != 6 || !units[0].IsKeyframe() {
+		t.Errorf("first access unit has %d NAL units, keyframe %v", len(units[0].NALs), units[0].IsKeyframe())
+	}
+	if units[1].IsKeyframe() || len(units[2].NALs) != 1 {
+		t.Errorf("unexpected delta access units %v", units[1:])
+	}
+}